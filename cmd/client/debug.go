@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// rawBytesPerLine is how many bytes of a field are shown on each line of the dump.
+const rawBytesPerLine = 16
+
+// runDebug runs the "debug" subcommands.
+func runDebug(args []string) error {
+	if len(args) == 0 || args[0] != "memory" {
+		return errors.New("runDebug: usage: ddstats debug memory [flags]")
+	}
+
+	flags := flag.NewFlagSet("debug memory", flag.ExitOnError)
+	var selector devildaggers.ProcessSelector
+	var playerID int
+	flags.IntVar(&selector.PID, "pid", 0, "inspect only the Devil Daggers process with this PID")
+	flags.StringVar(&selector.ExecutablePath, "exe", "", "inspect only a Devil Daggers process running this executable path or file name")
+	flags.IntVar(&playerID, "player-id", 0, "inspect only a Devil Daggers process logged in as this player ID")
+	interval := flags.Duration("interval", time.Second/2, "time between refreshes")
+	once := flags.Bool("once", false, "print the dump once and exit")
+	flags.Parse(args[1:])
+	selector.PlayerID = int32(playerID)
+
+	dd := devildaggers.New()
+	dd.SetProcessSelector(selector)
+	inspector := dd.NewInspector()
+	defer inspector.Close()
+
+	var buf bytes.Buffer
+	for {
+		buf.Reset()
+		if !*once {
+			// Move the cursor home and clear the screen.
+			buf.WriteString("\x1b[H\x1b[2J")
+		}
+		dump, err := inspector.Inspect()
+		writeMemoryDump(&buf, dump, err)
+		os.Stdout.Write(buf.Bytes())
+		if *once {
+			return err
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// writeMemoryDump prints how the block was found, an annotated dump of every
+// field and the first and last stats frames.
+func writeMemoryDump(w io.Writer, d *devildaggers.MemoryDump, err error) {
+	fmt.Fprintf(w, "ddstats debug memory - %s\n\n", time.Now().Format("15:04:05"))
+	if d == nil {
+		fmt.Fprintf(w, "Waiting for Devil Daggers: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(w, "Process        %s PID %d (%s)\n", d.Process.ExecutableName(), d.Process.PID, d.Process.ExecutablePath)
+	fmt.Fprintf(w, "Base address   0x%x\n", d.BaseAddress)
+	fmt.Fprintf(w, "Pointer        [0x%x] = 0x%x", d.PointerAddress, d.PointerValue)
+	if d.PointerErr != nil {
+		fmt.Fprintf(w, " (does not lead to the block: %v)", d.PointerErr)
+	}
+	fmt.Fprintln(w)
+	if d.HeaderAddress == 0 {
+		fmt.Fprintf(w, "\nBlock not found: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "Header         0x%x %q", d.HeaderAddress, d.Header)
+	if d.PointerErr != nil {
+		fmt.Fprint(w, " (found by scanning memory)")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Block          0x%x version %d, %d bytes", d.BlockAddress, d.Version, len(d.Block))
+	if d.LayoutVersion != d.Version {
+		fmt.Fprintf(w, " (unsupported, decoded with the version %d layout)", d.LayoutVersion)
+	}
+	fmt.Fprintln(w)
+	if err != nil {
+		fmt.Fprintf(w, "\nError: %v\n", err)
+	}
+	if len(d.Block) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w)
+	writeFields(w, d.Fields, d.Block)
+
+	if d.FirstFrame == nil {
+		fmt.Fprintf(w, "\nNo stats frames loaded.\n")
+		return
+	}
+	fmt.Fprintf(w, "\n%d stats frames loaded at 0x%x\n", d.Decoded.StatsFramesLoaded, d.Decoded.StatsBase)
+	for _, frame := range []*devildaggers.FrameDump{d.FirstFrame, d.LastFrame} {
+		fmt.Fprintf(w, "\nStats frame %d at 0x%x\n", frame.Index, frame.Address)
+		writeFields(w, frame.Fields, frame.Raw)
+	}
+}
+
+// writeFields prints one line per field of raw, wrapping long values onto extra
+// lines, and marks the bytes no field covers as padding.
+func writeFields(w io.Writer, fields []devildaggers.FieldDump, raw []byte) {
+	fmt.Fprintf(w, "%-6s %-4s %-22s %-48s %s\n", "OFFSET", "SIZE", "FIELD", "RAW", "VALUE")
+	pos := 0
+	for _, f := range fields {
+		if f.Offset > pos {
+			writeField(w, devildaggers.FieldDump{Name: "(padding)", Offset: pos, Raw: raw[pos:f.Offset]})
+		}
+		writeField(w, f)
+		pos = f.Offset + len(f.Raw)
+	}
+	if pos < len(raw) {
+		writeField(w, devildaggers.FieldDump{Name: "(padding)", Offset: pos, Raw: raw[pos:]})
+	}
+}
+
+func writeField(w io.Writer, f devildaggers.FieldDump) {
+	for i := 0; i < len(f.Raw) || i == 0; i += rawBytesPerLine {
+		end := i + rawBytesPerLine
+		if end > len(f.Raw) {
+			end = len(f.Raw)
+		}
+		hex := fmt.Sprintf("% x", f.Raw[i:end])
+		if i == 0 {
+			line := fmt.Sprintf("0x%04x %-4d %-22s %-48s %s", f.Offset, len(f.Raw), f.Name, hex, f.Value)
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+		} else {
+			fmt.Fprintf(w, "%-6s %-4s %-22s %s\n", "", "", "", hex)
+		}
+	}
+}
@@ -3,11 +3,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/alexwilkerson/ddstats-go/pkg/client"
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+	"github.com/alexwilkerson/ddstats-go/pkg/simulator"
+	"github.com/alexwilkerson/ddstats-go/pkg/spawnset"
 )
 
 const (
@@ -19,7 +25,67 @@ const (
 )
 
 func main() {
-	client, err := client.New(version, grpcAddr, v3survivalHash)
+	if len(os.Args) > 1 {
+		var command func([]string) error
+		switch os.Args[1] {
+		case "debug":
+			command = runDebug
+		case "replays":
+			command = runReplays
+		}
+		if command != nil {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	var opts client.Options
+	var playerID int
+	listProcesses := flag.Bool("list-processes", false, "list the running Devil Daggers processes and exit")
+	flag.IntVar(&opts.ProcessSelector.PID, "pid", 0, "attach only to the Devil Daggers process with this PID")
+	flag.StringVar(&opts.ProcessSelector.ExecutablePath, "exe", "", "attach only to a Devil Daggers process running this executable path or file name")
+	flag.IntVar(&playerID, "player-id", 0, "attach only to a Devil Daggers process logged in as this player ID")
+	flag.StringVar(&opts.RecordSession, "record-session", "", "record the game's memory to this session file")
+	playSession := flag.String("play-session", "", "play back a recorded session file instead of reading the game (offline)")
+	playbackSpeed := flag.Float64("playback-speed", 1, "speed to play back a session at")
+	demo := flag.Bool("demo", false, "play simulated runs instead of reading the game (offline)")
+	demoSeed := flag.Int64("demo-seed", 0, "seed of the simulated runs, 0 for a random seed")
+	demoSpawnset := flag.String("demo-spawnset", "", "spawnset file the simulated runs are played on")
+	demoSpeed := flag.Float64("demo-speed", 1, "speed to play the simulated runs at")
+	flag.Parse()
+	opts.ProcessSelector.PlayerID = int32(playerID)
+
+	if *demo && *playSession != "" {
+		log.Fatal("main: -demo and -play-session cannot be used together")
+	}
+
+	if *listProcesses {
+		if err := printProcesses(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *playSession != "" {
+		player, err := openSession(*playSession, *playbackSpeed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Locator = player
+	}
+
+	if *demo {
+		sim, err := newSimulator(*demoSeed, *demoSpawnset)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go sim.Run(context.Background(), *demoSpeed)
+		opts.Locator = sim
+	}
+
+	client, err := client.New(version, grpcAddr, v3survivalHash, opts)
 	if err != nil {
 		if err := logError(err); err != nil {
 			log.Fatal(err)
@@ -35,6 +101,55 @@ func main() {
 	}
 }
 
+func openSession(path string, speed float64) (*devildaggers.SessionPlayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("openSession: could not open session file: %w", err)
+	}
+	defer f.Close()
+
+	player, err := devildaggers.NewSessionPlayer(f, speed)
+	if err != nil {
+		return nil, fmt.Errorf("openSession: could not read session file: %w", err)
+	}
+	return player, nil
+}
+
+func newSimulator(seed int64, spawnsetPath string) (*simulator.Simulator, error) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	opts := simulator.DefaultOptions(seed)
+	if spawnsetPath != "" {
+		ss, err := spawnset.ReadFile(spawnsetPath)
+		if err != nil {
+			return nil, fmt.Errorf("newSimulator: could not read spawnset: %w", err)
+		}
+		opts.Spawnset = ss
+	}
+	return simulator.New(opts), nil
+}
+
+func printProcesses() error {
+	candidates, err := devildaggers.New().Discover()
+	if err != nil {
+		return fmt.Errorf("printProcesses: could not discover processes: %w", err)
+	}
+	if len(candidates) == 0 {
+		fmt.Println("No Devil Daggers processes found.")
+		return nil
+	}
+
+	fmt.Printf("%-8s %-8s %-10s %-20s %s\n", "PID", "VERSION", "PLAYER ID", "PLAYER", "EXECUTABLE")
+	for _, c := range candidates {
+		fmt.Printf("%-8d %-8d %-10d %-20s %s\n", c.PID, c.DDStatsVersion, c.PlayerID, c.PlayerName, c.ExecutablePath)
+		if c.Err != nil {
+			fmt.Printf("         error: %v\n", c.Err)
+		}
+	}
+	return nil
+}
+
 func logError(inputErr error) error {
 	f, err := os.OpenFile("error.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
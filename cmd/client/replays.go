@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+	"github.com/alexwilkerson/ddstats-go/pkg/replay"
+	"github.com/alexwilkerson/ddstats-go/pkg/spawnset"
+)
+
+// replayTimeTolerance is how far apart the times of a replay and a logged run
+// can be for them to be the same run.
+const replayTimeTolerance = 0.01
+
+// loggedRun is the part of a line of the client's run log a replay is matched
+// against.
+type loggedRun struct {
+	PlayerID     int32   `json:"player_id"`
+	LevelHashMD5 string  `json:"level_hash_md5"`
+	Time         float32 `json:"time"`
+	DeathType    string  `json:"death_type"`
+	GameID       int     `json:"game_id"`
+}
+
+// runReplays indexes a replays directory and cross-checks it with the run log.
+func runReplays(args []string) error {
+	flags := flag.NewFlagSet("replays", flag.ExitOnError)
+	runLog := flags.String("runs", "runs.jsonl", "run log to match the replays against")
+	spawnsetDir := flags.String("spawnsets", "spawnsets", "directory of spawnset files used to name spawnsets")
+	playerID := flags.Int("player-id", 0, "list only the replays of this player ID")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: ddstats replays [flags] [directory]")
+		fmt.Fprintln(flags.Output(), "\nThe directory defaults to the replays directory of the running game.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	dir := flags.Arg(0)
+	if dir == "" {
+		var err error
+		if dir, err = gameReplayDir(); err != nil {
+			return fmt.Errorf("runReplays: %w", err)
+		}
+	}
+
+	entries, err := replay.IndexDir(dir)
+	if err != nil {
+		return fmt.Errorf("runReplays: %w", err)
+	}
+	spawnsets, err := spawnset.NewRegistry()
+	if err != nil {
+		return fmt.Errorf("runReplays: %w", err)
+	}
+	if err := spawnsets.LoadDir(*spawnsetDir); err != nil {
+		var loadErr *spawnset.LoadError
+		if !errors.As(err, &loadErr) {
+			return fmt.Errorf("runReplays: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	runs, err := readRunLog(*runLog)
+	if err != nil {
+		return fmt.Errorf("runReplays: %w", err)
+	}
+
+	writeReplayIndex(os.Stdout, entries, spawnsets, runs, int32(*playerID))
+	return nil
+}
+
+// gameReplayDir returns the replays directory of the running game.
+func gameReplayDir() (string, error) {
+	candidates, err := devildaggers.New().Discover()
+	if err != nil {
+		return "", fmt.Errorf("gameReplayDir: could not discover processes: %w", err)
+	}
+	if len(candidates) == 0 {
+		return "", errors.New("gameReplayDir: Devil Daggers is not running, give the replays directory")
+	}
+	return filepath.Join(filepath.Dir(candidates[0].ExecutablePath), "replays"), nil
+}
+
+// readRunLog reads every run of the run log at path. A missing log has no runs.
+func readRunLog(path string) ([]loggedRun, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("readRunLog: could not open run log: %w", err)
+	}
+	defer f.Close()
+
+	var runs []loggedRun
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var run loggedRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			// Skip lines cut short by a crash rather than giving up on the log.
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("readRunLog: could not read run log: %w", err)
+	}
+	return runs, nil
+}
+
+// matchRun returns the logged run h replays, if any.
+func matchRun(h *replay.Header, runs []loggedRun) (loggedRun, bool) {
+	for _, run := range runs {
+		diff := run.Time - h.Time
+		if run.PlayerID == h.PlayerID &&
+			strings.EqualFold(run.LevelHashMD5, h.SpawnsetMD5String()) &&
+			run.DeathType == h.DeathType.String() &&
+			diff < replayTimeTolerance && diff > -replayTimeTolerance {
+			return run, true
+		}
+	}
+	return loggedRun{}, false
+}
+
+// writeReplayIndex prints one line per replay with the run it matches in the
+// run log, followed by the files that are not readable replays.
+func writeReplayIndex(w io.Writer, entries []replay.IndexEntry, spawnsets *spawnset.Registry, runs []loggedRun, playerID int32) {
+	fmt.Fprintf(w, "%-16s %-10s %-20s %9s %-12s %5s %6s %6s  %-20s %-12s %s\n",
+		"RECORDED", "PLAYER ID", "PLAYER", "TIME", "DEATH", "GEMS", "KILLS", "ACC", "SPAWNSET", "RUN", "FILE")
+	var listed, matched int
+	var failed []replay.IndexEntry
+	for _, e := range entries {
+		h := e.Header
+		if h == nil {
+			failed = append(failed, e)
+			continue
+		}
+		if playerID != 0 && h.PlayerID != playerID {
+			continue
+		}
+		listed++
+		run := "-"
+		if r, ok := matchRun(h, runs); ok {
+			matched++
+			run = "not submitted"
+			if r.GameID != 0 {
+				run = fmt.Sprintf("game %d", r.GameID)
+			}
+		}
+		fmt.Fprintf(w, "%-16s %-10d %-20s %9.4f %-12s %5d %6d %5.1f%%  %-20s %-12s %s\n",
+			h.RecordedAt().Local().Format("2006-01-02 15:04"), h.PlayerID, h.PlayerName, h.Time, h.DeathType,
+			h.Gems, h.Kills, h.Accuracy(), spawnsets.Name(h.SpawnsetMD5String()), run, filepath.Base(e.Path))
+	}
+
+	fmt.Fprintf(w, "\n%d replays, %d matching a logged run.\n", listed, matched)
+	for _, e := range failed {
+		fmt.Fprintf(w, "could not read %s: %v\n", filepath.Base(e.Path), e.Err)
+	}
+}
@@ -1,8 +1,12 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/alexwilkerson/ddstats-go/pkg/config"
@@ -10,6 +14,7 @@ import (
 	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
 	"github.com/alexwilkerson/ddstats-go/pkg/grpcclient"
 	"github.com/alexwilkerson/ddstats-go/pkg/socketio"
+	"github.com/alexwilkerson/ddstats-go/pkg/spawnset"
 	pb "github.com/alexwilkerson/ddstats-server/gamesubmission"
 	"github.com/atotto/clipboard"
 	ui "github.com/gizak/termui"
@@ -30,20 +35,75 @@ type Client struct {
 	ui                  *consoleui.ConsoleUI
 	uiData              *consoleui.Data
 	dd                  *devildaggers.DevilDaggers
-	grpcClient          *grpcclient.Client
+	spawnsets           *spawnset.Registry
+	grpcClient          gameRecorder
 	sioClient           *socketio.Client
+	recorder            *devildaggers.SessionRecorder
+	recordFile          *os.File
+	modPolicy           modPolicy
+	modded              int32
+	state               int32
 	loggedIn            bool
-	statsSent           bool
+	runID               string
+	runDecided          bool
+	retry               *failedSubmission
+	lastTimeline        savedTimeline
 	lastSubmittedGameID int
+	player              player
 	errChan             chan error
 	done                chan struct{}
 }
 
-func New(version string, grpcAddr, v3SurvivalHash string) (*Client, error) {
+// Options changes how the client finds and reads the game.
+type Options struct {
+	// ProcessSelector pins the client to a Devil Daggers process. If it is not
+	// zero it overrides the [process] section of the config.
+	ProcessSelector devildaggers.ProcessSelector
+	// Locator replaces the platform's process backend, e.g. to play back a
+	// recorded session. Nothing is sent to the server while it is set.
+	Locator devildaggers.ProcessLocator
+	// RecordSession is the path of a session file to record the game's memory to.
+	RecordSession string
+}
+
+// New creates a client.
+func New(version string, grpcAddr, v3SurvivalHash string, opts Options) (*Client, error) {
 	cfg, err := config.New()
 	if err != nil {
 		return nil, fmt.Errorf("New: unable to get config: %w", err)
 	}
+	if opts.Locator != nil {
+		cfg.OfflineMode = true
+	}
+
+	for _, name := range cfg.Stream.ExcludeCategories {
+		if _, err := spawnset.ParseCategory(name); err != nil {
+			return nil, fmt.Errorf("New: invalid [stream] exclude_categories: %w", err)
+		}
+	}
+	for _, name := range cfg.Submit.ExcludeCategories {
+		if _, err := spawnset.ParseCategory(name); err != nil {
+			return nil, fmt.Errorf("New: invalid [submit] exclude_categories: %w", err)
+		}
+	}
+
+	policy, err := parseModPolicy(cfg.Mods.Policy)
+	if err != nil {
+		return nil, fmt.Errorf("New: invalid config: %w", err)
+	}
+
+	spawnsets, err := spawnset.NewRegistry()
+	if err != nil {
+		return nil, fmt.Errorf("New: %w", err)
+	}
+	if err := spawnsets.LoadDir(cfg.Spawnsets.Directory); err != nil {
+		var loadErr *spawnset.LoadError
+		if !errors.As(err, &loadErr) {
+			return nil, fmt.Errorf("New: could not load spawnsets: %w", err)
+		}
+		// The spawnsets that could be read are used, and the others only logged.
+		logf("New: %v", err)
+	}
 
 	grpcClient, err := grpcclient.New(grpcAddr)
 	if err != nil {
@@ -85,6 +145,7 @@ func New(version string, grpcAddr, v3SurvivalHash string) (*Client, error) {
 		UpdateAvailable: updateAvailable,
 		ValidVersion:    validVersion,
 		Version:         version,
+		ModPolicy:       policy.String(),
 	}
 
 	ui, err := consoleui.New(&uiData)
@@ -93,6 +154,44 @@ func New(version string, grpcAddr, v3SurvivalHash string) (*Client, error) {
 	}
 
 	dd := devildaggers.New()
+	if opts.Locator != nil {
+		dd = devildaggers.NewWithLocator(opts.Locator)
+	}
+
+	var recorder *devildaggers.SessionRecorder
+	var recordFile *os.File
+	if opts.RecordSession != "" {
+		recordFile, err = os.Create(opts.RecordSession)
+		if err != nil {
+			return nil, fmt.Errorf("New: could not create session file: %w", err)
+		}
+		recorder, err = devildaggers.NewSessionRecorder(recordFile)
+		if err != nil {
+			recordFile.Close()
+			return nil, fmt.Errorf("New: could not start session recording: %w", err)
+		}
+		dd.SetSessionRecorder(recorder)
+	}
+
+	dd.SetHandStartResolver(spawnsets.HandStart)
+
+	if cfg.Timeline.Enabled {
+		dd.SetTimelineInterval(time.Duration(cfg.Timeline.IntervalMS) * time.Millisecond)
+	}
+
+	selector := opts.ProcessSelector
+	if selector.IsZero() {
+		selector = devildaggers.ProcessSelector{
+			PID:            cfg.Process.PID,
+			ExecutablePath: cfg.Process.ExecutablePath,
+			PlayerID:       cfg.Process.PlayerID,
+		}
+	}
+	dd.SetProcessSelector(selector)
+	uiData.ProcessSelector = selector.String()
+	if selector.IsZero() {
+		uiData.ProcessSelector = ""
+	}
 
 	return &Client{
 		version:        version,
@@ -103,8 +202,12 @@ func New(version string, grpcAddr, v3SurvivalHash string) (*Client, error) {
 		ui:             ui,
 		uiData:         &uiData,
 		dd:             dd,
+		spawnsets:      spawnsets,
+		modPolicy:      policy,
 		grpcClient:     grpcClient,
 		sioClient:      sioClient,
+		recorder:       recorder,
+		recordFile:     recordFile,
 		errChan:        make(chan error),
 		done:           make(chan struct{}),
 	}, nil
@@ -113,10 +216,14 @@ func New(version string, grpcAddr, v3SurvivalHash string) (*Client, error) {
 // Run starts the client.
 func (c *Client) Run() error {
 	defer c.ui.Close()
+	defer c.closeSessionRecording()
 	defer c.dd.StopPersistentConnection()
 	defer c.grpcClient.Close()
 
-	go c.run()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go c.run(ctx)
 
 	uiEvents := ui.PollEvents()
 	for {
@@ -138,9 +245,9 @@ func (c *Client) Run() error {
 	}
 }
 
-func (c *Client) run() {
+func (c *Client) run(ctx context.Context) {
 
-	c.dd.StartPersistentConnection(c.errChan)
+	c.dd.StartPersistentConnection(ctx)
 	go c.runDD()
 	go c.runUI()
 	if !c.cfg.OfflineMode {
@@ -158,56 +265,40 @@ func (c *Client) runSIO() {
 			}
 		}
 	}()
+	// loggedInAs is the account the sio client is logged in as.
+	var loggedInAs player
 	for {
 		select {
 		case <-time.After(defaultSIOTickRate):
-			if c.dd.CheckConnection() {
+			s := c.dd.Snapshot()
+			if s != nil && s.GetUnsupportedVersion() == 0 {
 				if c.sioClient.GetStatus() != socketio.StatusLoggedIn {
-					if c.dd.GetPlayerID() != 0 {
-						err := c.sioClient.Connect(int(c.dd.GetPlayerID()))
+					if s.GetPlayerID() != 0 {
+						err := c.sioClient.Connect(int(s.GetPlayerID()))
 						if err != nil {
 							c.errChan <- fmt.Errorf("runSIO: error connecting to sio: %w", err)
 							return
 						}
+						loggedInAs = playerOf(s)
+					}
+				} else if current := playerOf(s); current.switchedFrom(loggedInAs) {
+					// Log out so the next tick logs in as the new account.
+					err := c.sioClient.Disconnect()
+					if err != nil {
+						c.errChan <- fmt.Errorf("runSIO: error disconnecting from sio: %w", err)
+						return
 					}
 				} else {
-					if c.dd.GetIsInGame() || c.dd.GetStatus() == devildaggers.StatusDead {
-						if (c.cfg.Stream.Stats && !c.dd.GetIsReplay()) ||
-							(c.cfg.Stream.ReplayStats && c.dd.GetIsReplay()) {
-							if (c.dd.GetLevelHashMD5() == c.v3SurvivalHash) ||
-								(!c.cfg.Stream.NonDefaultSpawnsets && c.dd.GetLevelHashMD5() != c.v3SurvivalHash) {
-								var deathType int32 = -2
-								if c.dd.GetStatus() == devildaggers.StatusPlaying {
-									deathType = -1
-								} else if c.dd.GetStatus() == devildaggers.StatusDead {
-									deathType = int32(c.dd.GetDeathType())
-								}
-
-								notifyPlayerBest := c.cfg.Discord.NotifyPlayerBest
-								notifyAbove1000 := c.cfg.Discord.NotifyAbove1000
-
-								if c.dd.GetIsReplay() {
-									notifyPlayerBest = false
-									notifyAbove1000 = false
-								}
-
-								err := c.sioClient.SubmitStats(&socketio.SubmissionData{
-									PlayerID:         c.dd.GetPlayerID(),
-									Timer:            c.dd.GetTime(),
-									TotalGems:        c.dd.GetGemsCollected(),
-									Homing:           c.dd.GetHomingDaggers(),
-									EnemiesAlive:     c.dd.GetEnemiesAlive(),
-									EnemiesKilled:    c.dd.GetKills(),
-									DaggersHit:       c.dd.GetDaggersHit(),
-									DaggersFired:     c.dd.GetDaggersFired(),
-									Level2time:       c.dd.GetTimeLvl2(),
-									Level3time:       c.dd.GetTimeLvl3(),
-									Level4time:       c.dd.GetTimeLvl4(),
-									IsReplay:         c.dd.GetIsReplay(),
-									DeathType:        deathType,
-									NotifyPlayerBest: notifyPlayerBest,
-									NotifyAbove1000:  notifyAbove1000,
-								})
+					if !loggedInAs.known() {
+						loggedInAs = current
+					}
+					if c.runState().inRun() {
+						if (c.cfg.Stream.Stats && !s.GetIsReplay()) ||
+							(c.cfg.Stream.ReplayStats && s.GetIsReplay()) {
+							if c.spawnsetAllowed(s.GetLevelHashMD5(), c.cfg.Stream.NonDefaultSpawnsets) &&
+								!c.spawnsetExcluded(s.GetLevelHashMD5(), c.cfg.Stream.ExcludeCategories) &&
+								(!(s.GetProhibitedMods() || c.runModded()) || c.modPolicy.canStream()) {
+								err := c.sioClient.SubmitStats(c.streamedStats(s))
 								if err != nil {
 									c.errChan <- fmt.Errorf("runSIO: error sending stats via sio: %w", err)
 									return
@@ -216,7 +307,7 @@ func (c *Client) runSIO() {
 						}
 					} else {
 						var sioStatus int
-						switch c.dd.GetStatus() {
+						switch s.GetStatus() {
 						case devildaggers.StatusTitle, devildaggers.StatusMenu:
 							sioStatus = 4
 						case devildaggers.StatusLobby:
@@ -229,7 +320,7 @@ func (c *Client) runSIO() {
 							sioStatus = 3
 						}
 
-						err := c.sioClient.SubmitStatusUpdate(int(c.dd.GetPlayerID()), sioStatus)
+						err := c.sioClient.SubmitStatusUpdate(int(s.GetPlayerID()), sioStatus)
 						if err != nil {
 							c.errChan <- fmt.Errorf("runSIO: error sending status update via sio: %w", err)
 							return
@@ -252,112 +343,138 @@ func (c *Client) runSIO() {
 }
 
 func (c *Client) runDD() {
-	var oldStatus int32
+	events, unsubscribe := c.dd.Subscribe()
+	defer unsubscribe()
 	for {
 		select {
+		case e := <-events:
+			if (e.Type == devildaggers.EventRunStarted || e.Type == devildaggers.EventReplayStarted) &&
+				e.Boundary != devildaggers.BoundaryStatus {
+				logf("runDD: run %s started, detected by %s", e.Snapshot.GetRunID(), e.Boundary)
+			}
+			if e.Type == devildaggers.EventConnectionChanged && e.Connection.Err != nil {
+				logConnectionStatus(e.Connection)
+			}
+			if e.Type == devildaggers.EventCorruptRead {
+				logf("runDD: %v", e.Err)
+			}
+			if ended := timelineEnded(e); ended != nil && c.cfg.Timeline.Enabled {
+				c.saveRunTimeline(ended)
+			}
 		case <-time.After(c.tickRate):
-			if !c.dd.CheckConnection() {
+			s := c.dd.Snapshot()
+			c.uiData.AttachedProcess = c.dd.AttachedProcess()
+			if s == nil {
+				c.setRunState(runIdle)
 				c.clearUIData()
-				c.uiData.Status = consoleui.StatusDevilDaggersNotFound
+				conn := c.dd.ConnectionStatus()
+				c.uiData.ConnectionReason = conn.Reason()
+				switch conn.State {
+				case devildaggers.StateAttaching:
+					c.uiData.Status = consoleui.StatusConnecting
+				case devildaggers.StateIncompatible:
+					c.uiData.Status = consoleui.StatusIncompatible
+				default:
+					c.uiData.Status = consoleui.StatusDevilDaggersNotFound
+				}
 				c.uiData.OnlineStatus = c.sioClient.GetStatus()
 				continue
 			}
 
-			c.populateUIData()
-
-			newStatus := c.dd.GetStatus()
-
-			// when a new game has started
-			if oldStatus != devildaggers.StatusPlaying && newStatus == devildaggers.StatusPlaying ||
-				oldStatus != devildaggers.StatusOtherReplay && newStatus == devildaggers.StatusOtherReplay ||
-				oldStatus != devildaggers.StatusOwnReplayFromLeaderboard && newStatus == devildaggers.StatusOwnReplayFromLeaderboard {
-				c.statsSent = false
+			if version := s.GetUnsupportedVersion(); version != 0 {
+				c.setRunState(runIdle)
+				c.clearUIData()
+				c.uiData.Status = consoleui.StatusUnsupportedVersion
+				c.uiData.DDStatsVersion = version
+				c.uiData.OnlineStatus = c.sioClient.GetStatus()
+				continue
 			}
 
-			if !c.cfg.OfflineMode {
-				if c.dd.GetStatsFinishedLoading() && !c.statsSent {
-					if newStatus == devildaggers.StatusDead || newStatus == devildaggers.StatusOtherReplay || newStatus == devildaggers.StatusOwnReplayFromLeaderboard {
-						// send stats
-						submitGameRequest, err := c.compileGameRequest()
-						if err != nil {
-							c.errChan <- fmt.Errorf("runGameCapture: could not compile game recording: %w", err)
-							return
-						}
-						gameID, err := c.grpcClient.SubmitGame(submitGameRequest)
-						if err != nil {
-							c.errChan <- fmt.Errorf("runGameCapture: error submitting game to server: %w", err)
-							return
-						}
-						c.lastSubmittedGameID = gameID
-						c.statsSent = true
-
-						if c.cfg.AutoClipboardGame {
-							c.copyGameURLToClipboard()
-						}
+			c.checkPlayer(s)
 
-						if (c.cfg.Submit.Stats && !c.dd.GetIsReplay()) ||
-							(c.cfg.Submit.ReplayStats && c.dd.GetIsReplay()) {
-							if (c.dd.GetLevelHashMD5() == c.v3SurvivalHash) ||
-								(!c.cfg.Submit.NonDefaultSpawnsets && c.dd.GetLevelHashMD5() != c.v3SurvivalHash) {
-								if c.sioClient.GetStatus() == socketio.StatusLoggedIn {
-									notifyPlayerBest := c.cfg.Discord.NotifyPlayerBest
-									notifyAbove1000 := c.cfg.Discord.NotifyAbove1000
-									if c.dd.GetIsReplay() {
-										notifyPlayerBest = false
-										notifyAbove1000 = false
-									}
-									err = c.sioClient.SubmitGame(gameID, notifyPlayerBest, notifyAbove1000)
-									if err != nil {
-										c.errChan <- fmt.Errorf("runGameCapture: error submitting game to sio: %w", err)
-										return
-									}
-								}
-							}
-						}
-					}
-				}
+			if err := c.updateRun(s); err != nil {
+				c.errChan <- fmt.Errorf("runDD: %w", err)
+				return
 			}
 
-			oldStatus = newStatus
+			c.populateUIData(s)
 		case <-c.done:
+			c.giveUpRetry()
 			return
 		}
 	}
 }
 
-func (c *Client) compileGameRequest() (*pb.SubmitGameRequest, error) {
-	playerID := c.dd.GetPlayerID()
+// streamedStats returns the live stats of s sent to the server over sio.
+func (c *Client) streamedStats(s *devildaggers.Snapshot) *socketio.SubmissionData {
+	var deathType int32 = -2
+	if s.GetStatus() == devildaggers.StatusPlaying {
+		deathType = -1
+	} else if s.GetStatus() == devildaggers.StatusDead {
+		deathType = int32(s.GetDeathType())
+	}
+
+	notifyPlayerBest := c.cfg.Discord.NotifyPlayerBest
+	notifyAbove1000 := c.cfg.Discord.NotifyAbove1000
+
+	if s.GetIsReplay() {
+		notifyPlayerBest = false
+		notifyAbove1000 = false
+	}
+
+	return &socketio.SubmissionData{
+		PlayerID:         s.GetPlayerID(),
+		Timer:            s.GetTime(),
+		TotalGems:        s.GetGemsCollected(),
+		Homing:           s.GetHomingDaggers(),
+		EnemiesAlive:     s.GetEnemiesAlive(),
+		EnemiesKilled:    s.GetKills(),
+		DaggersHit:       s.GetDaggersHit(),
+		DaggersFired:     s.GetDaggersFired(),
+		Level2time:       s.GetTimeLvl2(),
+		Level3time:       s.GetTimeLvl3(),
+		Level4time:       s.GetTimeLvl4(),
+		IsReplay:         s.GetIsReplay(),
+		DeathType:        deathType,
+		NotifyPlayerBest: notifyPlayerBest,
+		NotifyAbove1000:  notifyAbove1000,
+		RunID:            s.GetRunID(),
+	}
+}
+
+func (c *Client) compileGameRequest(s *devildaggers.Snapshot) (*pb.SubmitGameRequest, error) {
+	playerID := s.GetPlayerID()
 	var replayPlayerID int32
-	if c.dd.GetIsReplay() {
-		playerID = c.dd.GetReplayPlayerID()
-		replayPlayerID = c.dd.GetPlayerID()
+	if s.GetIsReplay() {
+		playerID = s.GetReplayPlayerID()
+		replayPlayerID = s.GetPlayerID()
 	}
 	submitGameRequest := pb.SubmitGameRequest{
 		Version:              c.version,
 		PlayerID:             playerID,
-		PlayerName:           c.dd.GetPlayerName(),
-		LevelHashMD5:         c.dd.GetLevelHashMD5(),
-		TimeLvl2:             c.dd.GetTimeLvl2(),
-		TimeLvl3:             c.dd.GetTimeLvl3(),
-		TimeLvl4:             c.dd.GetTimeLvl4(),
-		TimeLeviDown:         c.dd.GetLeviathanDownTime(),
-		TimeOrbDown:          c.dd.GetOrbDownTime(),
-		EnemiesAliveMax:      c.dd.GetEnemiesAliveMax(),
-		EnemiesAliveMaxTime:  c.dd.GetEnemiesAliveMaxTime(),
-		HomingDaggersMax:     c.dd.GetHomingMax(),
-		HomingDaggersMaxTime: c.dd.GetHomingMaxTime(),
-		DeathType:            uint32(c.dd.GetDeathType()),
-		IsReplay:             c.dd.GetIsReplay(),
+		PlayerName:           s.GetPlayerName(),
+		LevelHashMD5:         s.GetLevelHashMD5(),
+		TimeLvl2:             s.GetTimeLvl2(),
+		TimeLvl3:             s.GetTimeLvl3(),
+		TimeLvl4:             s.GetTimeLvl4(),
+		TimeLeviDown:         s.GetLeviathanDownTime(),
+		TimeOrbDown:          s.GetOrbDownTime(),
+		EnemiesAliveMax:      s.GetEnemiesAliveMax(),
+		EnemiesAliveMaxTime:  s.GetEnemiesAliveMaxTime(),
+		HomingDaggersMax:     s.GetHomingMax(),
+		HomingDaggersMaxTime: s.GetHomingMaxTime(),
+		DeathType:            uint32(s.GetDeathType()),
+		IsReplay:             s.GetIsReplay(),
 		ReplayPlayerID:       replayPlayerID,
 		Stats:                []*pb.StatFrame{},
 	}
 
-	statsFrame, err := c.dd.GetStatsFrame()
-	if err != nil {
-		return nil, fmt.Errorf("newGameRecording: could not refresh stats frame: %w", err)
+	statsFrame := s.GetStatsFrame()
+	if len(statsFrame) == 0 {
+		return nil, errors.New("compileGameRequest: no stats frames were recorded")
 	}
 
-	startingGemOffset := c.dd.GetStartingGemOffset()
+	startingGems := s.GetHandStart().Gems()
 
 	for _, sf := range statsFrame {
 		perEnemyAliveCount := make([]int32, len(sf.PerEnemyAliveCount))
@@ -369,7 +486,7 @@ func (c *Client) compileGameRequest() (*pb.SubmitGameRequest, error) {
 			perEnemyKillCount[i] = int32(sf.PerEnemyKillCount[i])
 		}
 		submitGameRequest.Stats = append(submitGameRequest.Stats, &pb.StatFrame{
-			GemsCollected:      sf.GemsCollected + startingGemOffset,
+			GemsCollected:      sf.GemsCollected + startingGems,
 			Kills:              sf.Kills,
 			DaggersFired:       sf.DaggersFired,
 			DaggersHit:         sf.DaggersHit,
@@ -378,7 +495,7 @@ func (c *Client) compileGameRequest() (*pb.SubmitGameRequest, error) {
 			HomingDaggers:      sf.HomingDaggers,
 			GemsDespawned:      sf.GemsDespawned,
 			GemsEaten:          sf.GemsEaten,
-			TotalGems:          sf.TotalGems + startingGemOffset,
+			TotalGems:          sf.TotalGems + startingGems,
 			DaggersEaten:       sf.DaggersEaten,
 			PerEnemyAliveCount: perEnemyAliveCount,
 			PerEnemyKillCount:  perEnemyKillCount,
@@ -400,7 +517,7 @@ func (c *Client) compileGameRequest() (*pb.SubmitGameRequest, error) {
 	submitGameRequest.DaggersEaten = lastFrame.DaggersEaten
 	submitGameRequest.PerEnemyAliveCount = lastFrame.PerEnemyAliveCount
 	submitGameRequest.PerEnemyKillcount = lastFrame.PerEnemyKillCount
-	submitGameRequest.Time = c.dd.GetTimeMax()
+	submitGameRequest.Time = s.GetTimeMax()
 
 	return &submitGameRequest, nil
 }
@@ -424,7 +541,6 @@ func (c *Client) runUI() {
 
 func (c *Client) clearUIData() {
 	c.uiData.PlayerName = ""
-	c.uiData.Recording = consoleui.StatusNotRecording
 	c.uiData.Timer = 0.0
 	c.uiData.DaggersHit = 0
 	c.uiData.DaggersFired = 0
@@ -436,44 +552,99 @@ func (c *Client) clearUIData() {
 	c.uiData.GemsDespawned = 0
 	c.uiData.GemsEaten = 0
 	c.uiData.DeathType = 0
+	c.uiData.Spawnset = ""
+	c.uiData.HandProgress = devildaggers.HandProgress{}
+	c.uiData.ProhibitedMods = false
 }
 
-func (c *Client) populateUIData() {
-	c.uiData.Status = c.dd.GetStatus()
+func (c *Client) populateUIData(s *devildaggers.Snapshot) {
+	c.uiData.Status = s.GetStatus()
 	c.uiData.OnlineStatus = c.sioClient.GetStatus()
-	c.uiData.PlayerName = c.dd.GetPlayerName()
+	c.uiData.PlayerName = s.GetPlayerName()
 	if c.uiData.PlayerName == "" {
 		c.uiData.Status = consoleui.StatusConnecting
 		return
 	}
 	c.uiData.LastGameID = c.lastSubmittedGameID
-	status := c.dd.GetStatus()
+	c.uiData.ProhibitedMods = s.GetProhibitedMods() || c.runModded()
+	c.uiData.Spawnset = ""
+	if s.GetIsInGame() || s.GetStatus() == devildaggers.StatusDead {
+		c.uiData.Spawnset = c.spawnsets.Name(s.GetLevelHashMD5())
+	}
+	status := s.GetStatus()
 	if status == devildaggers.StatusPlaying || status == devildaggers.StatusOtherReplay || status == devildaggers.StatusOwnReplayFromLastRun || status == devildaggers.StatusOwnReplayFromLeaderboard {
-		c.uiData.Recording = consoleui.StatusRecording
-		if c.statsSent {
-			c.uiData.Recording = consoleui.StatusGameSubmitted
-		}
-		c.uiData.Timer = c.dd.GetTime()
-		c.uiData.DaggersHit = c.dd.GetDaggersHit()
-		c.uiData.DaggersFired = c.dd.GetDaggersFired()
-		c.uiData.Accuracy = c.dd.GetAccuracy()
-		c.uiData.GemsCollected = c.dd.GetGemsCollected()
-		c.uiData.Homing = c.dd.GetHomingDaggers()
-		c.uiData.EnemiesAlive = c.dd.GetEnemiesAlive()
-		c.uiData.EnemiesKilled = c.dd.GetKills()
-		c.uiData.TotalGems = c.dd.GetTotalGems()
-		c.uiData.GemsDespawned = c.dd.GetGemsDespawned()
-		c.uiData.GemsEaten = c.dd.GetGemsEaten()
-		c.uiData.DaggersEaten = c.dd.GetDaggersEaten()
-	} else {
-		c.uiData.Recording = consoleui.StatusNotRecording
-		if c.dd.GetStatus() == devildaggers.StatusDead {
-			if c.statsSent {
-				c.uiData.Recording = consoleui.StatusGameSubmitted
-			}
-			c.uiData.DeathType = c.dd.GetDeathType()
+		c.uiData.Timer = s.GetTime()
+		c.uiData.DaggersHit = s.GetDaggersHit()
+		c.uiData.DaggersFired = s.GetDaggersFired()
+		c.uiData.Accuracy = s.GetAccuracy()
+		c.uiData.GemsCollected = s.GetGemsCollected()
+		c.uiData.Homing = s.GetHomingDaggers()
+		c.uiData.EnemiesAlive = s.GetEnemiesAlive()
+		c.uiData.EnemiesKilled = s.GetKills()
+		c.uiData.TotalGems = s.GetTotalGems()
+		c.uiData.GemsDespawned = s.GetGemsDespawned()
+		c.uiData.GemsEaten = s.GetGemsEaten()
+		c.uiData.DaggersEaten = s.GetDaggersEaten()
+		c.uiData.HandProgress = s.GetHandProgress()
+	} else if s.GetStatus() == devildaggers.StatusDead {
+		c.uiData.DeathType = s.GetDeathType()
+	}
+}
+
+// spawnsetAllowed reports whether runs on the spawnset with the given hash pass
+// the non_default_spawnsets option of the [stream] or [submit] section.
+func (c *Client) spawnsetAllowed(hash string, nonDefaultSpawnsets bool) bool {
+	return hash == c.v3SurvivalHash || !nonDefaultSpawnsets
+}
+
+// spawnsetExcluded reports whether the registry puts the spawnset with the given
+// hash in one of the excluded categories.
+func (c *Client) spawnsetExcluded(hash string, excludeCategories []string) bool {
+	category := c.spawnsets.Category(hash).String()
+	for _, excluded := range excludeCategories {
+		if strings.EqualFold(excluded, category) {
+			return true
 		}
 	}
+	return false
+}
+
+// logRun records the end of a run in the run log, along with the state it was
+// left in and what was decided about prohibited mods.
+func (c *Client) logRun(s *devildaggers.Snapshot, gameID int, st runState, skipReason string) {
+	if err := appendRunRecord(c.newRunRecord(s, gameID, st, skipReason)); err != nil {
+		logf("logRun: %v", err)
+	}
+}
+
+// closeSessionRecording finishes the session file, once the game has been closed
+// so that the file ends with the process detaching.
+func (c *Client) closeSessionRecording() {
+	if c.recorder == nil {
+		return
+	}
+	c.dd.SetSessionRecorder(nil)
+	if err := c.recorder.Close(); err != nil {
+		logf("closeSessionRecording: %v", err)
+	}
+	c.recordFile.Close()
+}
+
+// logConnectionStatus appends a failed connection attempt to the error log, so
+// users can find out why the client could not attach to the game.
+func logConnectionStatus(status devildaggers.ConnectionStatus) {
+	logf("devil daggers connection %s: %v", status.State, status.Err)
+}
+
+// logf appends a line to the error log without stopping the client.
+func logf(format string, v ...interface{}) {
+	f, err := os.OpenFile("error.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	log.New(f, "", log.LstdFlags).Printf(format+"\n", v...)
 }
 
 func (c *Client) copyGameURLToClipboard() {
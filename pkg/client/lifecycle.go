@@ -0,0 +1,298 @@
+package client
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/consoleui"
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+	"github.com/alexwilkerson/ddstats-go/pkg/socketio"
+	pb "github.com/alexwilkerson/ddstats-server/gamesubmission"
+)
+
+// runState is where the run on screen is in its lifecycle. runDD moves it on
+// every tick, and it alone decides what is submitted, what the UI shows and
+// what is streamed. The transitions are:
+//
+//	idle, in-lobby, submitted, failed, skipped -> idle, in-lobby, recording
+//	recording      -> idle, in-lobby, awaiting-stats, submitting, skipped
+//	awaiting-stats -> idle, in-lobby, submitting, skipped
+//	submitting     -> submitted, failed
+//	failed         -> submitting, when the submission is retried
+//
+// idle and in-lobby also go straight to awaiting-stats, submitting or skipped
+// when the client attaches to a game showing a run that is already over.
+type runState int32
+
+const (
+	// runIdle is when no run is on screen: in the menus or away from the game.
+	runIdle runState = iota
+	runInLobby
+	runRecording
+	// runAwaitingStats is when the run is over and the game has not finished
+	// loading its stats.
+	runAwaitingStats
+	runSubmitting
+	runSubmitted
+	// runFailed is when the run could not be submitted. It is retried with a
+	// backoff, up to submitAttempts times in all.
+	runFailed
+	// runSkipped is when the run is over and is not submitted, because of the
+	// config or of prohibited mods.
+	runSkipped
+)
+
+const (
+	// submitAttempts is how many times a run is sent to the server before it is
+	// given up on.
+	submitAttempts = 4
+	// submitRetryBackoff is the wait before a run is sent again after its first
+	// failed attempt. It doubles after every attempt.
+	submitRetryBackoff = 5 * time.Second
+)
+
+// gameRecorder is the server runs are submitted to.
+type gameRecorder interface {
+	SubmitGame(game *pb.SubmitGameRequest, runID string) (int, error)
+	Close()
+}
+
+// failedSubmission is a run that could not be submitted and is to be sent again.
+type failedSubmission struct {
+	s        *devildaggers.Snapshot
+	attempts int
+	retryAt  time.Time
+}
+
+var runStateNames = [...]string{"idle", "in-lobby", "recording", "awaiting-stats", "submitting", "submitted", "failed", "skipped"}
+
+func (st runState) String() string {
+	if st < 0 || int(st) >= len(runStateNames) {
+		return "unknown"
+	}
+	return runStateNames[st]
+}
+
+// decided reports whether the run is over and it was decided whether to submit it.
+func (st runState) decided() bool {
+	return st == runSubmitted || st == runFailed || st == runSkipped
+}
+
+// inRun reports whether a run, played or replayed, is on screen.
+func (st runState) inRun() bool {
+	return st >= runRecording
+}
+
+// nextRunState returns the state st moves to when the game shows status. decided
+// is set once the run on screen was submitted or skipped, which never happens
+// twice for a run. A run over moves to awaiting-stats, which finishRun then
+// moves on.
+func nextRunState(st runState, status int32, decided bool) runState {
+	switch status {
+	case devildaggers.StatusTitle, devildaggers.StatusMenu:
+		return runIdle
+	case devildaggers.StatusLobby:
+		return runInLobby
+	case devildaggers.StatusPlaying:
+		return runRecording
+	case devildaggers.StatusOwnReplayFromLastRun:
+		// The replay of the run just played is the same run.
+		return st
+	}
+	// Dead, or watching a replay whose stats are known from the start.
+	if decided || st.decided() {
+		return st
+	}
+	return runAwaitingStats
+}
+
+// recordingStatus returns the recording status the UI shows for st.
+func recordingStatus(st runState) int {
+	switch st {
+	case runRecording:
+		return consoleui.StatusRecording
+	case runAwaitingStats:
+		return consoleui.StatusAwaitingStats
+	case runSubmitting:
+		return consoleui.StatusSubmitting
+	case runSubmitted:
+		return consoleui.StatusGameSubmitted
+	case runFailed:
+		return consoleui.StatusSubmitFailed
+	case runSkipped:
+		return consoleui.StatusNotSubmitted
+	}
+	return consoleui.StatusNotRecording
+}
+
+// runState returns the state of the run on screen. It is set by runDD and read
+// by runSIO.
+func (c *Client) runState() runState {
+	return runState(atomic.LoadInt32(&c.state))
+}
+
+// setRunState moves the run to st and shows it in the UI.
+func (c *Client) setRunState(st runState) {
+	atomic.StoreInt32(&c.state, int32(st))
+	c.uiData.Recording = recordingStatus(st)
+	if st == runFailed && c.retry != nil && c.retry.s.GetRunID() == c.runID {
+		c.uiData.Recording = consoleui.StatusSubmitRetrying
+	}
+}
+
+// updateRun moves the run lifecycle on from s. A new run starts over from idle,
+// unless it is the replay of the run just played, prohibited mods seen at any
+// point mark the run as modded, and a run that is over is submitted or skipped.
+// A run that could not be submitted is sent again once its backoff is over. The
+// error is only set if the client cannot go on.
+func (c *Client) updateRun(s *devildaggers.Snapshot) error {
+	if id := s.GetRunID(); id != c.runID {
+		c.runID = id
+		if s.GetStatus() != devildaggers.StatusOwnReplayFromLastRun {
+			c.runDecided = false
+			c.setRunModded(false)
+			c.setRunState(runIdle)
+		}
+	}
+	if s.GetProhibitedMods() && (s.GetIsInGame() || s.GetStatus() == devildaggers.StatusDead) {
+		c.setRunModded(true)
+	}
+
+	st := nextRunState(c.runState(), s.GetStatus(), c.runDecided)
+	if st == runAwaitingStats {
+		var err error
+		if st, err = c.finishRun(s); err != nil {
+			return fmt.Errorf("updateRun: %w", err)
+		}
+	}
+	c.setRunState(st)
+	if err := c.retrySubmission(time.Now()); err != nil {
+		return fmt.Errorf("updateRun: %w", err)
+	}
+	return nil
+}
+
+// finishRun decides what happens to a run that is over and returns the state it
+// is left in. Every run decided on is added to the run log.
+func (c *Client) finishRun(s *devildaggers.Snapshot) (runState, error) {
+	switch {
+	case c.cfg.OfflineMode:
+		return c.skipRun(s, skipReasonOfflineMode), nil
+	case c.runModded() && !c.modPolicy.canSubmit():
+		return c.skipRun(s, skipReasonProhibitedMods), nil
+	case c.spawnsetExcluded(s.GetLevelHashMD5(), c.cfg.Submit.ExcludeCategories):
+		return c.skipRun(s, skipReasonExcludedCategory), nil
+	case !s.GetStatsFinishedLoading() || !s.GetStatsFramesComplete():
+		return runAwaitingStats, nil
+	}
+
+	c.setRunState(runSubmitting)
+	c.runDecided = true
+	st, err := c.submit(s, 1, time.Now())
+	if err != nil {
+		return st, fmt.Errorf("finishRun: %w", err)
+	}
+	return st, nil
+}
+
+// submit makes the attempt-th try at sending the run that ended in s to the
+// server, and returns the state the run is left in. A run that could not be
+// sent is retried later, and only added to the run log as failed once the last
+// attempt failed. Only one run is retried at a time, so a pending retry of an
+// earlier run is given up on when another run fails.
+func (c *Client) submit(s *devildaggers.Snapshot, attempt int, now time.Time) (runState, error) {
+	if c.retry != nil && c.retry.s.GetRunID() != s.GetRunID() {
+		c.giveUpRetry()
+	}
+	gameID, err := c.submitRun(s)
+	if err != nil {
+		logf("submit: attempt %d of %d at submitting run %s: %v", attempt, submitAttempts, s.GetRunID(), err)
+		if attempt < submitAttempts {
+			c.retry = &failedSubmission{s: s, attempts: attempt, retryAt: now.Add(submitRetryBackoff << (attempt - 1))}
+		} else {
+			c.retry = nil
+			c.logRun(s, 0, runFailed, "")
+		}
+		return runFailed, nil
+	}
+	c.retry = nil
+	c.lastSubmittedGameID = gameID
+	c.logRun(s, gameID, runSubmitted, "")
+
+	if c.cfg.AutoClipboardGame {
+		c.copyGameURLToClipboard()
+	}
+
+	if (c.cfg.Submit.Stats && !s.GetIsReplay()) ||
+		(c.cfg.Submit.ReplayStats && s.GetIsReplay()) {
+		if c.spawnsetAllowed(s.GetLevelHashMD5(), c.cfg.Submit.NonDefaultSpawnsets) {
+			if c.sioClient.GetStatus() == socketio.StatusLoggedIn {
+				notifyPlayerBest := c.cfg.Discord.NotifyPlayerBest
+				notifyAbove1000 := c.cfg.Discord.NotifyAbove1000
+				if s.GetIsReplay() {
+					notifyPlayerBest = false
+					notifyAbove1000 = false
+				}
+				err = c.sioClient.SubmitGame(gameID, s.GetRunID(), notifyPlayerBest, notifyAbove1000)
+				if err != nil {
+					return runSubmitted, fmt.Errorf("submit: error submitting game to sio: %w", err)
+				}
+			}
+		}
+	}
+	return runSubmitted, nil
+}
+
+// retrySubmission sends the run that could not be submitted again, once its
+// backoff is over at now. If the run is still on screen, its state follows.
+func (c *Client) retrySubmission(now time.Time) error {
+	r := c.retry
+	if r == nil || now.Before(r.retryAt) {
+		return nil
+	}
+	onScreen := r.s.GetRunID() == c.runID && c.runState() == runFailed
+	if onScreen {
+		c.setRunState(runSubmitting)
+	}
+	st, err := c.submit(r.s, r.attempts+1, now)
+	if onScreen {
+		c.setRunState(st)
+	}
+	if err != nil {
+		return fmt.Errorf("retrySubmission: %w", err)
+	}
+	return nil
+}
+
+// giveUpRetry stops retrying the run that could not be submitted, if any, and
+// adds it to the run log as failed.
+func (c *Client) giveUpRetry() {
+	if c.retry == nil {
+		return
+	}
+	logf("giveUpRetry: run %s not submitted after %d attempts", c.retry.s.GetRunID(), c.retry.attempts)
+	c.logRun(c.retry.s, 0, runFailed, "")
+	c.retry = nil
+}
+
+// skipRun decides not to submit the run that ended in s, for the given reason.
+func (c *Client) skipRun(s *devildaggers.Snapshot, reason string) runState {
+	c.runDecided = true
+	c.logRun(s, 0, runSkipped, reason)
+	return runSkipped
+}
+
+// submitRun sends the run that ended in s to the server and returns the ID the
+// server gave it.
+func (c *Client) submitRun(s *devildaggers.Snapshot) (int, error) {
+	submitGameRequest, err := c.compileGameRequest(s)
+	if err != nil {
+		return 0, fmt.Errorf("submitRun: could not compile game recording: %w", err)
+	}
+	gameID, err := c.grpcClient.SubmitGame(submitGameRequest, s.GetRunID())
+	if err != nil {
+		return 0, fmt.Errorf("submitRun: error submitting game to server: %w", err)
+	}
+	return gameID, nil
+}
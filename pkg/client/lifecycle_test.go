@@ -0,0 +1,498 @@
+package client
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/config"
+	"github.com/alexwilkerson/ddstats-go/pkg/consoleui"
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+	"github.com/alexwilkerson/ddstats-go/pkg/socketio"
+	"github.com/alexwilkerson/ddstats-go/pkg/spawnset"
+	pb "github.com/alexwilkerson/ddstats-server/gamesubmission"
+)
+
+const testV3SurvivalHash = "569fead87abf4d30fdee4231a6398051"
+
+var allRunStates = []runState{runIdle, runInLobby, runRecording, runAwaitingStats, runSubmitting, runSubmitted, runFailed, runSkipped}
+
+func TestNextRunState(t *testing.T) {
+	// want and wantDecided are the next state from each of allRunStates, with
+	// decided unset and set.
+	tests := []struct {
+		status      int32
+		want        [8]runState
+		wantDecided [8]runState
+	}{
+		{
+			devildaggers.StatusTitle,
+			[8]runState{runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle},
+			[8]runState{runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle},
+		},
+		{
+			devildaggers.StatusMenu,
+			[8]runState{runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle},
+			[8]runState{runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle, runIdle},
+		},
+		{
+			devildaggers.StatusLobby,
+			[8]runState{runInLobby, runInLobby, runInLobby, runInLobby, runInLobby, runInLobby, runInLobby, runInLobby},
+			[8]runState{runInLobby, runInLobby, runInLobby, runInLobby, runInLobby, runInLobby, runInLobby, runInLobby},
+		},
+		{
+			devildaggers.StatusPlaying,
+			[8]runState{runRecording, runRecording, runRecording, runRecording, runRecording, runRecording, runRecording, runRecording},
+			[8]runState{runRecording, runRecording, runRecording, runRecording, runRecording, runRecording, runRecording, runRecording},
+		},
+		{
+			devildaggers.StatusDead,
+			[8]runState{runAwaitingStats, runAwaitingStats, runAwaitingStats, runAwaitingStats, runAwaitingStats, runSubmitted, runFailed, runSkipped},
+			[8]runState{runIdle, runInLobby, runRecording, runAwaitingStats, runSubmitting, runSubmitted, runFailed, runSkipped},
+		},
+		{
+			devildaggers.StatusOwnReplayFromLastRun,
+			[8]runState{runIdle, runInLobby, runRecording, runAwaitingStats, runSubmitting, runSubmitted, runFailed, runSkipped},
+			[8]runState{runIdle, runInLobby, runRecording, runAwaitingStats, runSubmitting, runSubmitted, runFailed, runSkipped},
+		},
+		{
+			devildaggers.StatusOwnReplayFromLeaderboard,
+			[8]runState{runAwaitingStats, runAwaitingStats, runAwaitingStats, runAwaitingStats, runAwaitingStats, runSubmitted, runFailed, runSkipped},
+			[8]runState{runIdle, runInLobby, runRecording, runAwaitingStats, runSubmitting, runSubmitted, runFailed, runSkipped},
+		},
+		{
+			devildaggers.StatusOtherReplay,
+			[8]runState{runAwaitingStats, runAwaitingStats, runAwaitingStats, runAwaitingStats, runAwaitingStats, runSubmitted, runFailed, runSkipped},
+			[8]runState{runIdle, runInLobby, runRecording, runAwaitingStats, runSubmitting, runSubmitted, runFailed, runSkipped},
+		},
+	}
+	for _, tt := range tests {
+		for i, st := range allRunStates {
+			if got := nextRunState(st, tt.status, false); got != tt.want[i] {
+				t.Errorf("nextRunState(%s, %d, false) = %s, want %s", st, tt.status, got, tt.want[i])
+			}
+			if got := nextRunState(st, tt.status, true); got != tt.wantDecided[i] {
+				t.Errorf("nextRunState(%s, %d, true) = %s, want %s", st, tt.status, got, tt.wantDecided[i])
+			}
+		}
+	}
+}
+
+// fakeRecorder is a server that fails the submissions it has errors for, in
+// order, and accepts the others as game 42.
+type fakeRecorder struct {
+	errs   []error
+	calls  int
+	runIDs []string
+}
+
+func (r *fakeRecorder) SubmitGame(game *pb.SubmitGameRequest, runID string) (int, error) {
+	r.calls++
+	r.runIDs = append(r.runIDs, runID)
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 42, nil
+}
+
+func (r *fakeRecorder) Close() {}
+
+var errServerDown = errors.New("server down")
+
+// inTempDir runs the rest of the test in a new directory, so that the run log
+// and error log are written there.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// newTestClient returns a client that submits runs to rec and is not logged in
+// to sio.
+func newTestClient(t *testing.T, rec gameRecorder) *Client {
+	t.Helper()
+	spawnsets, err := spawnset.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sioClient, err := socketio.New("http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{
+		v3SurvivalHash: testV3SurvivalHash,
+		cfg:            &config.Config{Submit: config.SubmitConfig{Stats: true}},
+		uiData:         &consoleui.Data{},
+		spawnsets:      spawnsets,
+		grpcClient:     rec,
+		sioClient:      sioClient,
+	}
+}
+
+// testBlock returns the block of a V3 run that died after 60 seconds, with its
+// stats loaded.
+func testBlock() devildaggers.DataBlock {
+	b := devildaggers.DataBlock{
+		DDStatsVersion:       1,
+		PlayerID:             21854,
+		Time:                 60,
+		TimeMax:              60,
+		GemsCollected:        20,
+		TotalGems:            20,
+		Kills:                30,
+		DaggersFired:         400,
+		DaggersHit:           100,
+		DeathType:            uint8(devildaggers.DeathEviscerated),
+		Status:               devildaggers.StatusDead,
+		StatsFinishedLoading: true,
+		StatsFramesLoaded:    3,
+	}
+	copy(b.UserName[:], "xvlv")
+	hex.Decode(b.LevelHashMD5[:], []byte(testV3SurvivalHash))
+	return b
+}
+
+// newFakeGame returns a game showing b with frames stats frames, and a connection
+// reading it.
+func newFakeGame(t *testing.T, b devildaggers.DataBlock, frames int) (*devildaggers.FakeProcess, *devildaggers.DevilDaggers) {
+	t.Helper()
+	f := devildaggers.NewFakeProcess()
+	f.SetDataBlock(b)
+	f.SetStatsFrames(make([]devildaggers.StatsFrame, frames))
+	dd := devildaggers.NewWithLocator(f)
+	if connected, err := dd.Connect(); !connected || err != nil {
+		t.Fatalf("Connect() = %v, %v, want true, nil", connected, err)
+	}
+	if err := dd.RefreshData(); err != nil {
+		t.Fatalf("RefreshData() = %v", err)
+	}
+	return f, dd
+}
+
+// readRunLog returns the runs added to the run log.
+func readRunLog(t *testing.T) []runRecord {
+	t.Helper()
+	f, err := os.Open(runLogFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []runRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r runRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("run log line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestFinishRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		offline    bool
+		modded     bool
+		policy     modPolicy
+		excluded   []string
+		block      func(b *devildaggers.DataBlock)
+		frames     int
+		errs       []error
+		want       runState
+		wantCalls  int
+		wantLogged string
+		wantSkip   string
+	}{
+		{name: "submitted", frames: 3, want: runSubmitted, wantCalls: 1, wantLogged: "submitted"},
+		{name: "offline", offline: true, frames: 3, want: runSkipped, wantLogged: "skipped", wantSkip: skipReasonOfflineMode},
+		{name: "modded with the block policy", modded: true, policy: modPolicyBlock, frames: 3, want: runSkipped, wantLogged: "skipped", wantSkip: skipReasonProhibitedMods},
+		{name: "modded with the stream only policy", modded: true, policy: modPolicyStreamOnly, frames: 3, want: runSkipped, wantLogged: "skipped", wantSkip: skipReasonProhibitedMods},
+		{name: "modded with the flag policy", modded: true, policy: modPolicyFlag, frames: 3, want: runSubmitted, wantCalls: 1, wantLogged: "submitted"},
+		{name: "excluded category", excluded: []string{"Survival"}, frames: 3, want: runSkipped, wantLogged: "skipped", wantSkip: skipReasonExcludedCategory},
+		{
+			name:   "stats not loaded",
+			block:  func(b *devildaggers.DataBlock) { b.StatsFinishedLoading = false },
+			frames: 3,
+			want:   runAwaitingStats,
+		},
+		{name: "frames incomplete", frames: 2, want: runAwaitingStats},
+		{name: "submit error", frames: 3, errs: []error{errServerDown}, want: runFailed, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempDir(t)
+			rec := &fakeRecorder{errs: tt.errs}
+			c := newTestClient(t, rec)
+			c.cfg.OfflineMode = tt.offline
+			c.cfg.Submit.ExcludeCategories = tt.excluded
+			c.modPolicy = tt.policy
+			b := testBlock()
+			if tt.block != nil {
+				tt.block(&b)
+			}
+			_, dd := newFakeGame(t, b, tt.frames)
+			s := dd.Snapshot()
+			c.runID = s.GetRunID()
+			c.setRunModded(tt.modded)
+
+			got, err := c.finishRun(s)
+			if err != nil {
+				t.Fatalf("finishRun() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("finishRun() = %s, want %s", got, tt.want)
+			}
+			if c.runDecided != tt.want.decided() {
+				t.Errorf("runDecided = %v, want %v", c.runDecided, tt.want.decided())
+			}
+			if rec.calls != tt.wantCalls {
+				t.Errorf("%d submissions, want %d", rec.calls, tt.wantCalls)
+			}
+			for _, id := range rec.runIDs {
+				if id != s.GetRunID() {
+					t.Errorf("submitted with run ID %q, want %q", id, s.GetRunID())
+				}
+			}
+
+			records := readRunLog(t)
+			if tt.wantLogged == "" {
+				if len(records) != 0 {
+					t.Fatalf("run log = %+v, want no runs", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("run log = %+v, want one run", records)
+			}
+			r := records[0]
+			if r.State != tt.wantLogged || r.SkipReason != tt.wantSkip || r.RunID != s.GetRunID() || r.ProhibitedMods != tt.modded {
+				t.Errorf("run log = %+v, want state %q, skip reason %q and prohibited mods %v", r, tt.wantLogged, tt.wantSkip, tt.modded)
+			}
+			if tt.want == runSubmitted && (r.GameID != 42 || c.lastSubmittedGameID != 42) {
+				t.Errorf("game ID %d logged and %d kept, want 42", r.GameID, c.lastSubmittedGameID)
+			}
+		})
+	}
+}
+
+func TestSubmitRetry(t *testing.T) {
+	inTempDir(t)
+	rec := &fakeRecorder{errs: []error{errServerDown, errServerDown}}
+	c := newTestClient(t, rec)
+	_, dd := newFakeGame(t, testBlock(), 3)
+	s := dd.Snapshot()
+	c.runID = s.GetRunID()
+
+	st, err := c.finishRun(s)
+	if err != nil {
+		t.Fatalf("finishRun() = %v", err)
+	}
+	c.setRunState(st)
+	if st != runFailed || c.uiData.Recording != consoleui.StatusSubmitRetrying {
+		t.Fatalf("state %s shown as %d, want failed and retrying", st, c.uiData.Recording)
+	}
+
+	firstRetry := c.retry.retryAt
+	if err := c.retrySubmission(firstRetry.Add(-time.Millisecond)); err != nil || rec.calls != 1 {
+		t.Fatalf("retrySubmission() before the backoff = %v with %d submissions, want nil with 1", err, rec.calls)
+	}
+	if err := c.retrySubmission(firstRetry); err != nil || rec.calls != 2 {
+		t.Fatalf("retrySubmission() = %v with %d submissions, want nil with 2", err, rec.calls)
+	}
+	if c.runState() != runFailed || c.retry == nil {
+		t.Fatalf("state %s after the second failure, want failed with a retry pending", c.runState())
+	}
+	if backoff := c.retry.retryAt.Sub(firstRetry); backoff != 2*submitRetryBackoff {
+		t.Errorf("second backoff = %v, want %v", backoff, 2*submitRetryBackoff)
+	}
+	if records := readRunLog(t); len(records) != 0 {
+		t.Fatalf("run log = %+v while retrying, want no runs", records)
+	}
+
+	if err := c.retrySubmission(c.retry.retryAt); err != nil || rec.calls != 3 {
+		t.Fatalf("retrySubmission() = %v with %d submissions, want nil with 3", err, rec.calls)
+	}
+	if c.runState() != runSubmitted || c.retry != nil || c.uiData.Recording != consoleui.StatusGameSubmitted {
+		t.Errorf("state %s shown as %d with retry %+v, want submitted", c.runState(), c.uiData.Recording, c.retry)
+	}
+	if records := readRunLog(t); len(records) != 1 || records[0].State != "submitted" || records[0].GameID != 42 {
+		t.Errorf("run log = %+v, want the run submitted as game 42", records)
+	}
+}
+
+func TestSubmitRetryGivesUp(t *testing.T) {
+	inTempDir(t)
+	rec := &fakeRecorder{errs: []error{errServerDown, errServerDown, errServerDown, errServerDown}}
+	c := newTestClient(t, rec)
+	_, dd := newFakeGame(t, testBlock(), 3)
+	s := dd.Snapshot()
+	c.runID = s.GetRunID()
+
+	st, err := c.finishRun(s)
+	if err != nil {
+		t.Fatalf("finishRun() = %v", err)
+	}
+	c.setRunState(st)
+	for c.retry != nil {
+		if err := c.retrySubmission(c.retry.retryAt); err != nil {
+			t.Fatalf("retrySubmission() = %v", err)
+		}
+	}
+	if rec.calls != submitAttempts {
+		t.Errorf("%d submissions, want %d", rec.calls, submitAttempts)
+	}
+	if c.runState() != runFailed || c.uiData.Recording != consoleui.StatusSubmitFailed {
+		t.Errorf("state %s shown as %d, want failed", c.runState(), c.uiData.Recording)
+	}
+	if records := readRunLog(t); len(records) != 1 || records[0].State != "failed" {
+		t.Errorf("run log = %+v, want the run failed", records)
+	}
+}
+
+func TestSubmitRetryGivenUpOnForNextRun(t *testing.T) {
+	inTempDir(t)
+	rec := &fakeRecorder{errs: []error{errServerDown, errServerDown}}
+	c := newTestClient(t, rec)
+	_, dd := newFakeGame(t, testBlock(), 3)
+	first := dd.Snapshot()
+	_, dd = newFakeGame(t, testBlock(), 3)
+	second := dd.Snapshot()
+	if first.GetRunID() == second.GetRunID() {
+		t.Fatal("two games share a run ID")
+	}
+
+	c.runID = first.GetRunID()
+	c.finishRun(first)
+	c.runID = second.GetRunID()
+	c.finishRun(second)
+	if c.retry == nil || c.retry.s != second {
+		t.Fatalf("retry = %+v, want the second run", c.retry)
+	}
+	records := readRunLog(t)
+	if len(records) != 1 || records[0].RunID != first.GetRunID() || records[0].State != "failed" {
+		t.Fatalf("run log = %+v, want the first run failed", records)
+	}
+
+	c.giveUpRetry()
+	if records := readRunLog(t); len(records) != 2 || records[1].RunID != second.GetRunID() || records[1].State != "failed" {
+		t.Errorf("run log = %+v, want the second run failed once given up on", records)
+	}
+}
+
+func TestUpdateRun(t *testing.T) {
+	inTempDir(t)
+	rec := &fakeRecorder{}
+	c := newTestClient(t, rec)
+	playing := testBlock()
+	playing.Status = devildaggers.StatusPlaying
+	playing.IsInGame = true
+	playing.IsPlayerAlive = true
+	playing.StatsFinishedLoading = false
+	f, dd := newFakeGame(t, playing, 3)
+
+	dead := testBlock()
+	loading := dead
+	loading.StatsFinishedLoading = false
+	replay := dead
+	replay.Status = devildaggers.StatusOwnReplayFromLastRun
+	menu := dead
+	menu.Status = devildaggers.StatusMenu
+
+	steps := []struct {
+		name      string
+		block     devildaggers.DataBlock
+		want      runState
+		wantCalls int
+	}{
+		{"playing", playing, runRecording, 0},
+		{"dead with the stats loading", loading, runAwaitingStats, 0},
+		{"dead with the stats loaded", dead, runSubmitted, 1},
+		{"still dead", dead, runSubmitted, 1},
+		{"replay of the run", replay, runSubmitted, 1},
+		{"menu", menu, runIdle, 1},
+	}
+	for _, step := range steps {
+		f.SetDataBlock(step.block)
+		if err := dd.RefreshData(); err != nil {
+			t.Fatalf("%s: RefreshData() = %v", step.name, err)
+		}
+		if err := c.updateRun(dd.Snapshot()); err != nil {
+			t.Fatalf("%s: updateRun() = %v", step.name, err)
+		}
+		if c.runState() != step.want || rec.calls != step.wantCalls {
+			t.Errorf("%s: state %s with %d submissions, want %s with %d", step.name, c.runState(), rec.calls, step.want, step.wantCalls)
+		}
+	}
+}
+
+func TestStreamedStats(t *testing.T) {
+	c := newTestClient(t, &fakeRecorder{})
+	c.cfg.Discord.NotifyPlayerBest = true
+	_, dd := newFakeGame(t, testBlock(), 3)
+	s := dd.Snapshot()
+
+	got := c.streamedStats(s)
+	if got.RunID == "" || got.RunID != s.GetRunID() {
+		t.Errorf("RunID = %q, want %q", got.RunID, s.GetRunID())
+	}
+	if got.DeathType != int32(devildaggers.DeathEviscerated) || got.Timer != 60 || !got.NotifyPlayerBest {
+		t.Errorf("streamedStats() = %+v", got)
+	}
+}
+
+func TestSubmitRetryDuringReplay(t *testing.T) {
+	inTempDir(t)
+	rec := &fakeRecorder{errs: []error{errServerDown}}
+	c := newTestClient(t, rec)
+	playing := testBlock()
+	playing.Status = devildaggers.StatusPlaying
+	playing.IsInGame = true
+	playing.IsPlayerAlive = true
+	playing.StatsFinishedLoading = false
+	f, dd := newFakeGame(t, playing, 3)
+
+	dead := testBlock()
+	replay := dead
+	replay.Status = devildaggers.StatusOwnReplayFromLastRun
+	replay.Time = 0.2
+	for _, b := range []devildaggers.DataBlock{playing, dead, replay} {
+		f.SetDataBlock(b)
+		if err := dd.RefreshData(); err != nil {
+			t.Fatalf("RefreshData() = %v", err)
+		}
+		if err := c.updateRun(dd.Snapshot()); err != nil {
+			t.Fatalf("updateRun() = %v", err)
+		}
+	}
+	if c.runState() != runFailed || c.uiData.Recording != consoleui.StatusSubmitRetrying {
+		t.Fatalf("state %s shown as %d in the replay, want failed and retrying", c.runState(), c.uiData.Recording)
+	}
+
+	if err := c.retrySubmission(c.retry.retryAt); err != nil {
+		t.Fatalf("retrySubmission() = %v", err)
+	}
+	if c.runState() != runSubmitted || c.uiData.Recording != consoleui.StatusGameSubmitted {
+		t.Errorf("state %s shown as %d after the retry, want submitted", c.runState(), c.uiData.Recording)
+	}
+	if rec.calls != 2 {
+		t.Errorf("%d submissions, want 2", rec.calls)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// modPolicy is what the client does with runs played with prohibited mods, set
+// by the [mods] section of the config.
+type modPolicy int
+
+const (
+	// modPolicyBlock neither streams nor submits modded runs.
+	modPolicyBlock modPolicy = iota
+	// modPolicyFlag streams and submits modded runs, and flags them as modded in
+	// the run log.
+	modPolicyFlag
+	// modPolicyStreamOnly streams modded runs but does not submit them.
+	modPolicyStreamOnly
+)
+
+var modPolicyNames = [...]string{"block", "flag", "stream_only"}
+
+func (p modPolicy) String() string {
+	return modPolicyNames[p]
+}
+
+func parseModPolicy(name string) (modPolicy, error) {
+	if name == "" {
+		return modPolicyBlock, nil
+	}
+	for i, n := range modPolicyNames {
+		if name == n {
+			return modPolicy(i), nil
+		}
+	}
+	return modPolicyBlock, fmt.Errorf("parseModPolicy: unknown mods policy %q", name)
+}
+
+// Decisions recorded in the run log about a run's prohibited mods.
+const (
+	modDecisionNone       = "none"
+	modDecisionBlocked    = "blocked"
+	modDecisionFlagged    = "flagged"
+	modDecisionStreamOnly = "stream_only"
+)
+
+// canStream reports whether the policy lets a modded run be streamed.
+func (p modPolicy) canStream() bool {
+	return p != modPolicyBlock
+}
+
+// canSubmit reports whether the policy lets a modded run be submitted.
+func (p modPolicy) canSubmit() bool {
+	return p == modPolicyFlag
+}
+
+// decision returns what is recorded in the run log for a run under the policy.
+func (p modPolicy) decision(modded bool) string {
+	if !modded {
+		return modDecisionNone
+	}
+	switch p {
+	case modPolicyFlag:
+		return modDecisionFlagged
+	case modPolicyStreamOnly:
+		return modDecisionStreamOnly
+	}
+	return modDecisionBlocked
+}
+
+// runModded reports whether prohibited mods were detected at any point of the
+// current run. It is set by runDD and read by runSIO.
+func (c *Client) runModded() bool {
+	return atomic.LoadInt32(&c.modded) != 0
+}
+
+func (c *Client) setRunModded(modded bool) {
+	var v int32
+	if modded {
+		v = 1
+	}
+	atomic.StoreInt32(&c.modded, v)
+}
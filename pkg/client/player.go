@@ -0,0 +1,52 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// player identifies the account the game is logged in as.
+type player struct {
+	id   int32
+	name string
+}
+
+func playerOf(s *devildaggers.Snapshot) player {
+	return player{id: s.GetPlayerID(), name: s.GetPlayerName()}
+}
+
+// known reports whether the game has finished loading the account, which it
+// does some time after starting.
+func (p player) known() bool {
+	return p.id != 0 && p.name != ""
+}
+
+// switchedFrom reports whether p is a different account from prev. Nothing is
+// a switch while either account is not known.
+func (p player) switchedFrom(prev player) bool {
+	return p.known() && prev.known() && p != prev
+}
+
+func (p player) String() string {
+	return fmt.Sprintf("%s (%d)", p.name, p.id)
+}
+
+// checkPlayer resets the per-player state when the game is logged in as a
+// different account than in the previous snapshots, e.g. after switching Steam
+// accounts, and tells the UI about it. When the process is pinned to a player ID
+// the switch never shows here: devildaggers detaches from the game instead, and
+// the connection reason tells the UI why.
+func (c *Client) checkPlayer(s *devildaggers.Snapshot) {
+	current := playerOf(s)
+	if current.switchedFrom(c.player) {
+		logf("runDD: player changed from %s to %s", c.player, current)
+		c.lastSubmittedGameID = 0
+		c.uiData.PlayerSwitch = fmt.Sprintf("Player changed from %s to %s", c.player, current)
+		c.uiData.PlayerSwitchTime = time.Now()
+	}
+	if current.known() {
+		c.player = current
+	}
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// runLogFile is where a line is appended for every finished run the client
+// decides whether to submit.
+const runLogFile = "runs.jsonl"
+
+type runRecord struct {
+	RunID          string    `json:"run_id"`
+	RecordedAt     time.Time `json:"recorded_at"`
+	PlayerID       int32     `json:"player_id"`
+	PlayerName     string    `json:"player_name"`
+	LevelHashMD5   string    `json:"level_hash_md5"`
+	Spawnset       string    `json:"spawnset"`
+	IsReplay       bool      `json:"is_replay"`
+	Time           float32   `json:"time"`
+	DeathType      string    `json:"death_type"`
+	GameID         int       `json:"game_id,omitempty"`
+	ProhibitedMods bool      `json:"prohibited_mods"`
+	ModDecision    string    `json:"mod_decision"`
+	State          string    `json:"state"`
+	SkipReason     string    `json:"skip_reason,omitempty"`
+}
+
+// Reasons recorded in the run log for a run that was skipped.
+const (
+	skipReasonOfflineMode      = "offline_mode"
+	skipReasonProhibitedMods   = "prohibited_mods"
+	skipReasonExcludedCategory = "excluded_category"
+)
+
+// newRunRecord describes the run that ended in s and the state it was left in.
+// gameID is the ID the server gave the run, or 0 if it was not submitted, and
+// skipReason is why a skipped run was not submitted.
+func (c *Client) newRunRecord(s *devildaggers.Snapshot, gameID int, st runState, skipReason string) runRecord {
+	// The client only knows of prohibited mods seen earlier in the run it is on.
+	modded := s.GetProhibitedMods() || (s.GetRunID() == c.runID && c.runModded())
+	return runRecord{
+		RunID:          s.GetRunID(),
+		RecordedAt:     time.Now(),
+		PlayerID:       s.GetPlayerID(),
+		PlayerName:     s.GetPlayerName(),
+		LevelHashMD5:   s.GetLevelHashMD5(),
+		Spawnset:       c.spawnsets.Name(s.GetLevelHashMD5()),
+		IsReplay:       s.GetIsReplay(),
+		Time:           s.GetTimeMax(),
+		DeathType:      s.GetDeathType().String(),
+		GameID:         gameID,
+		ProhibitedMods: modded,
+		ModDecision:    c.modPolicy.decision(modded),
+		State:          st.String(),
+		SkipReason:     skipReason,
+	}
+}
+
+// appendRunRecord adds r to the run log.
+func appendRunRecord(r runRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("appendRunRecord: could not encode run: %w", err)
+	}
+	f, err := os.OpenFile(runLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("appendRunRecord: could not open run log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("appendRunRecord: could not write run log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+	"github.com/alexwilkerson/ddstats-go/pkg/spawnset"
+)
+
+// timelineDir is where the timelines of finished runs are saved.
+const timelineDir = "timelines"
+
+type timelineFile struct {
+	RunID        string                        `json:"run_id"`
+	PlayerID     int32                         `json:"player_id"`
+	PlayerName   string                        `json:"player_name"`
+	LevelHashMD5 string                        `json:"level_hash_md5"`
+	Spawnset     string                        `json:"spawnset"`
+	Category     string                        `json:"spawnset_category"`
+	IsReplay     bool                          `json:"is_replay"`
+	Time         float32                       `json:"time"`
+	DeathType    string                        `json:"death_type"`
+	Modded       bool                          `json:"prohibited_mods"`
+	Samples      []devildaggers.TimelineSample `json:"samples"`
+}
+
+// savedTimeline identifies the last timeline written, so that a run whose end is
+// seen more than once is only written again if its timeline grew.
+type savedTimeline struct {
+	runID   string
+	samples int
+}
+
+// timelineEnded returns the snapshot holding the timeline of a run that e shows
+// to be over, or nil. A run is over when the player dies or goes back to the
+// menu, and when another run starts, which covers restarts and relaunches.
+func timelineEnded(e devildaggers.Event) *devildaggers.Snapshot {
+	switch e.Type {
+	case devildaggers.EventDied, devildaggers.EventReturnedToMenu:
+		return e.Snapshot
+	case devildaggers.EventRunStarted, devildaggers.EventReplayStarted:
+		return e.Ended
+	}
+	return nil
+}
+
+// saveRunTimeline saves the timeline of the run that ended in s, unless it was
+// already saved.
+func (c *Client) saveRunTimeline(s *devildaggers.Snapshot) {
+	saved := savedTimeline{runID: s.GetRunID(), samples: len(s.GetTimeline())}
+	if saved.runID == "" || saved.samples == 0 || saved == c.lastTimeline {
+		return
+	}
+	// The client only knows of prohibited mods seen earlier in the run it is on.
+	modded := s.GetProhibitedMods() || (saved.runID == c.runID && c.runModded())
+	if err := saveTimeline(s, c.spawnsets, modded); err != nil {
+		logf("saveRunTimeline: %v", err)
+		return
+	}
+	c.lastTimeline = saved
+}
+
+// saveTimeline writes the timeline of the run that ended in s to the timeline
+// directory, in a file named after the run ID.
+func saveTimeline(s *devildaggers.Snapshot, spawnsets *spawnset.Registry, modded bool) error {
+	samples := s.GetTimeline()
+	if len(samples) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(timelineFile{
+		RunID:        s.GetRunID(),
+		PlayerID:     s.GetPlayerID(),
+		PlayerName:   s.GetPlayerName(),
+		LevelHashMD5: s.GetLevelHashMD5(),
+		Spawnset:     spawnsets.Name(s.GetLevelHashMD5()),
+		Category:     spawnsets.Category(s.GetLevelHashMD5()).String(),
+		IsReplay:     s.GetIsReplay(),
+		Time:         s.GetTime(),
+		DeathType:    s.GetDeathType().String(),
+		Modded:       modded,
+		Samples:      samples,
+	})
+	if err != nil {
+		return fmt.Errorf("saveTimeline: could not encode timeline: %w", err)
+	}
+
+	if err := os.MkdirAll(timelineDir, 0755); err != nil {
+		return fmt.Errorf("saveTimeline: could not create timeline directory: %w", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(timelineDir, s.GetRunID()+".json"), b, 0644); err != nil {
+		return fmt.Errorf("saveTimeline: could not write timeline: %w", err)
+	}
+	return nil
+}
@@ -29,6 +29,12 @@ func New() (*Config, error) {
 			NotifyAbove1000:  true,
 			NotifyPlayerBest: true,
 		},
+		Spawnsets: SpawnsetsConfig{
+			Directory: "spawnsets",
+		},
+		Mods: ModsConfig{
+			Policy: "block",
+		},
 	}
 
 	if _, err := toml.DecodeFile("config.toml", &config); err != nil {
@@ -48,18 +54,24 @@ type Config struct {
 	Stream            StreamConfig
 	Submit            SubmitConfig
 	Discord           DiscordConfig
+	Process           ProcessConfig
+	Timeline          TimelineConfig
+	Spawnsets         SpawnsetsConfig
+	Mods              ModsConfig
 }
 
 type StreamConfig struct {
 	Stats               bool
-	ReplayStats         bool `toml:"replay_stats"`
-	NonDefaultSpawnsets bool `toml:"non_default_spawnsets"`
+	ReplayStats         bool     `toml:"replay_stats"`
+	NonDefaultSpawnsets bool     `toml:"non_default_spawnsets"`
+	ExcludeCategories   []string `toml:"exclude_categories"`
 }
 
 type SubmitConfig struct {
 	Stats               bool
-	ReplayStats         bool `toml:"replay_stats"`
-	NonDefaultSpawnsets bool `toml:"non_default_spawnsets"`
+	ReplayStats         bool     `toml:"replay_stats"`
+	NonDefaultSpawnsets bool     `toml:"non_default_spawnsets"`
+	ExcludeCategories   []string `toml:"exclude_categories"`
 }
 
 type DiscordConfig struct {
@@ -67,6 +79,25 @@ type DiscordConfig struct {
 	NotifyPlayerBest bool `toml:"notify_player_best"`
 }
 
+type ProcessConfig struct {
+	PID            int    `toml:"pid"`
+	ExecutablePath string `toml:"executable_path"`
+	PlayerID       int32  `toml:"player_id"`
+}
+
+type TimelineConfig struct {
+	Enabled    bool `toml:"enabled"`
+	IntervalMS int  `toml:"interval_ms"`
+}
+
+type SpawnsetsConfig struct {
+	Directory string `toml:"directory"`
+}
+
+type ModsConfig struct {
+	Policy string `toml:"policy"`
+}
+
 const defaultConfigFile = `# DDSTATS CONFIGURATION FILE.
 # If you mess up this file, press F12 while ddstats.exe is running and the default file will be written.
 
@@ -85,26 +116,69 @@ host = "https://ddstats.com"
 # "stats" are your stats in a normal run.
 # "replay_stats" are stats while you're watching a replay.
 # "non_default_spawnsets" are stats in a run where you are using an alternative survival file.
+# "exclude_categories" lists spawnset categories whose runs are never streamed: "survival", "practice", "race", "custom" or "unknown". See the [spawnsets] section.
 [stream]
 stats = true
 replay_stats = true
 non_default_spawnsets = true
+exclude_categories = []
 
 # These options are for whether ddstats submits your completed games to ddstats.com.
 # "stats" are your stats in a normal run.
 # "replay_stats" are stats while you're watching a replay.
 # "non_default_spawnsets" are stats in a run where you are using an alternative survival file.
+# "exclude_categories" lists spawnset categories whose runs are never submitted: "survival", "practice", "race", "custom" or "unknown". See the [spawnsets] section.
 [submit]
 stats = true
 replay_stats = true
 non_default_spawnsets = true
+exclude_categories = []
 
 # By default, if your game goes above 1000 or if you beat your best time, the ddstats Discord Bot will notify the DevilDaggers.info and DD PALS discord channels. You can disable that feature here.
 # "notify_above_1000" notifies when your score goes above 1000 seconds.
 # "notify_player_best" notifies when your score goes above your current high score.
 [discord]
 notify_above_1000 = true
-notify_player_best = true`
+notify_player_best = true
+
+# These options pin ddstats to one Devil Daggers process when more than one is running, e.g. a modded build alongside the retail one.
+# Options left at 0 or "" match any process. Run ddstats with -list-processes to see the running processes.
+# "pid" is the process ID to attach to.
+# "executable_path" is the full path of the executable, or only its file name, e.g. "dd.exe".
+# "player_id" is the ID of the player the game is logged in as.
+[process]
+pid = 0
+executable_path = ""
+player_id = 0
+
+# The timeline records your time, gems, homing daggers and enemies many times a second, rather than once a second like the game's stats.
+# When a run ends, by dying, quitting or restarting, its timeline is saved to the "timelines" folder in a file named after its run ID.
+# "enabled" turns the timeline on.
+# "interval_ms" is the least amount of game time between two samples, in milliseconds. 0 takes a sample every time the game is read.
+[timeline]
+enabled = false
+interval_ms = 0
+
+# ddstats knows the names and categories of some spawnsets, and shows the name of the one you are playing.
+# "directory" is a folder of spawnset files to name after their file, in the "custom" category.
+# The folder can also hold a "spawnsets.toml" file giving spawnsets a name, author, category and notes, e.g.
+#   [[spawnset]]
+#   hash = "569fead87abf4d30fdee4231a6398051"
+#   name = "V3 Survival"
+#   author = "Sorath"
+#   category = "survival"
+#   notes = "The default spawnset of Devil Daggers V3."
+[spawnsets]
+directory = "spawnsets"
+
+# Devil Daggers reports when a run is played with prohibited mods. This option decides what ddstats does with those runs.
+# Every finished run is written to "runs.jsonl" along with what was decided.
+# "policy" is one of:
+#   "block" neither streams nor submits modded runs.
+#   "flag" streams and submits modded runs, and marks them as modded in "runs.jsonl".
+#   "stream_only" streams modded runs but does not submit them.
+[mods]
+policy = "block"`
 
 func WriteDefaultConfigFile() error {
 	if err := ioutil.WriteFile("config.toml", []byte(defaultConfigFile), 0644); err != nil {
@@ -21,12 +21,23 @@ const (
 	StatusOtherReplay
 	StatusConnecting
 	StatusDevilDaggersNotFound
+	StatusUnsupportedVersion
+	StatusIncompatible
 )
 
+// playerSwitchNoticeDuration is how long the MOTD is replaced by a notice that
+// the player changed.
+const playerSwitchNoticeDuration = 10 * time.Second
+
 const (
 	StatusNotRecording = iota
 	StatusRecording
 	StatusGameSubmitted
+	StatusAwaitingStats
+	StatusSubmitting
+	StatusSubmitFailed
+	StatusNotSubmitted
+	StatusSubmitRetrying
 )
 
 const (
@@ -70,8 +81,27 @@ type Data struct {
 	GemsEaten       int32
 	TotalGems       int32
 	DaggersEaten    int32
-	DeathType       uint8
+	DeathType       devildaggers.DeathType
 	LastGameID      int
+	DDStatsVersion  int32
+	// ConnectionReason explains why Devil Daggers could not be attached to.
+	ConnectionReason string
+	// AttachedProcess is the Devil Daggers process being read.
+	AttachedProcess devildaggers.ProcessInfo
+	// ProcessSelector describes the process the client is pinned to, if any.
+	ProcessSelector string
+	// Spawnset is the name of the spawnset being played, if in a run.
+	Spawnset string
+	// HandProgress is the hand level of the run and the progress to the next one.
+	HandProgress devildaggers.HandProgress
+	// ProhibitedMods is set while the game reports prohibited mods in the run.
+	ProhibitedMods bool
+	// ModPolicy is the [mods] policy, which decides what happens to modded runs.
+	ModPolicy string
+	// PlayerSwitch describes the last change of the account the game is logged
+	// in as, and PlayerSwitchTime is when it happened.
+	PlayerSwitch     string
+	PlayerSwitchTime time.Time
 }
 
 type ConsoleUI struct {
@@ -105,6 +135,7 @@ func (cui *ConsoleUI) DrawScreen() error {
 		cui.drawUpdateAvailable()
 	}
 	cui.drawMOTD()
+	cui.drawProcess()
 	err := cui.drawStatus()
 	if err != nil {
 		return fmt.Errorf("DrawScreen: error drawing status: %w", err)
@@ -114,6 +145,7 @@ func (cui *ConsoleUI) DrawScreen() error {
 	cui.drawLeftSideStats()
 	cui.drawRightSideStats()
 	cui.drawLastGameLabel()
+	cui.drawSpawnset()
 
 	return nil
 }
@@ -199,6 +231,14 @@ func (cui *ConsoleUI) drawUpdateAvailable() {
 }
 
 func (cui *ConsoleUI) drawMOTD() {
+	if cui.data.ProhibitedMods {
+		cui.drawModsWarning()
+		return
+	}
+	if time.Since(cui.data.PlayerSwitchTime) < playerSwitchNoticeDuration {
+		cui.drawPlayerSwitch()
+		return
+	}
 	motdLabel := ui.NewParagraph(cui.data.MOTD)
 	motdLabel.X = ui.TermWidth()/2 - len(cui.data.MOTD)/2
 	motdLabel.Border = false
@@ -209,12 +249,57 @@ func (cui *ConsoleUI) drawMOTD() {
 	ui.Render(motdLabel)
 }
 
+func (cui *ConsoleUI) drawModsWarning() {
+	var warning string
+	switch cui.data.ModPolicy {
+	case "flag":
+		warning = "PROHIBITED MODS: run will be submitted as modded"
+	case "stream_only":
+		warning = "PROHIBITED MODS: run will not be submitted"
+	default:
+		warning = "PROHIBITED MODS: run will not be streamed or submitted"
+	}
+
+	warningLabel := ui.NewParagraph(warning)
+	warningLabel.TextFgColor = ui.StringToAttribute("bold, red")
+	warningLabel.X = ui.TermWidth()/2 - len(warning)/2
+	warningLabel.Border = false
+	warningLabel.Y = 12
+	warningLabel.Height = 1
+	warningLabel.Width = len(warning) + 1
+
+	ui.Render(warningLabel)
+}
+
+func (cui *ConsoleUI) drawPlayerSwitch() {
+	notice := cui.data.PlayerSwitch
+	if len(notice) > 66 {
+		notice = notice[:63] + "..."
+	}
+	// Pad to the width of the MOTD line so the notice hides the MOTD, and the
+	// MOTD drawn after it is not left with stray characters.
+	notice = fmt.Sprintf("%-66s", fmt.Sprintf("%*s", 33+len(notice)/2, notice))
+
+	noticeLabel := ui.NewParagraph(notice)
+	noticeLabel.TextFgColor = ui.StringToAttribute("bold, yellow")
+	noticeLabel.X = ui.TermWidth()/2 - 33
+	noticeLabel.Border = false
+	noticeLabel.Y = 12
+	noticeLabel.Height = 1
+	noticeLabel.Width = len(notice) + 1
+
+	ui.Render(noticeLabel)
+}
+
 func (cui *ConsoleUI) drawStatus() error {
 	statusLabel := ui.NewParagraph("")
 	var statusString string
 	switch cui.data.Status {
 	case StatusDevilDaggersNotFound:
 		statusString = "Devil Daggers not found"
+		if cui.data.ConnectionReason != "" {
+			statusString = "Cannot attach to Devil Daggers: " + cui.data.ConnectionReason
+		}
 		statusLabel.TextFgColor = ui.StringToAttribute("red")
 	case StatusTitleScreen:
 		statusString = "In title screen"
@@ -237,12 +322,14 @@ func (cui *ConsoleUI) drawStatus() error {
 	case StatusConnecting:
 		statusString = "Connecting to Devil Daggers"
 		statusLabel.TextFgColor = ui.StringToAttribute("yellow")
+	case StatusUnsupportedVersion:
+		statusString = fmt.Sprintf("Unsupported game/ddstats block version %d", cui.data.DDStatsVersion)
+		statusLabel.TextFgColor = ui.StringToAttribute("red")
+	case StatusIncompatible:
+		statusString = "Incompatible Devil Daggers: " + cui.data.ConnectionReason
+		statusLabel.TextFgColor = ui.StringToAttribute("red")
 	case StatusDead:
-		deathType, err := devildaggers.GetDeathTypeString(int(cui.data.DeathType))
-		if err != nil {
-			return fmt.Errorf("drawStatus: could not get death type string: %w", err)
-		}
-		statusString = deathType
+		statusString = cui.data.DeathType.String()
 		statusLabel.TextFgColor = ui.StringToAttribute("red")
 	}
 	statusLabel.Border = false
@@ -257,6 +344,50 @@ func (cui *ConsoleUI) drawStatus() error {
 	return nil
 }
 
+func (cui *ConsoleUI) drawProcess() {
+	processString := "No process attached"
+	if cui.data.AttachedProcess.PID != 0 {
+		processString = fmt.Sprintf("%s PID %d", cui.data.AttachedProcess.ExecutableName(), cui.data.AttachedProcess.PID)
+		if cui.data.ProcessSelector != "" {
+			processString += " (pinned)"
+		}
+	} else if cui.data.ProcessSelector != "" {
+		processString = "Waiting for " + cui.data.ProcessSelector
+	}
+	if len(processString) > 46 {
+		processString = processString[:43] + "..."
+	}
+
+	processLabel := ui.NewParagraph(processString)
+	processLabel.TextFgColor = ui.StringToAttribute("yellow")
+	processLabel.Border = false
+	processLabel.X = ui.TermWidth()/2 + 33 - len(processString)
+	processLabel.Y = 0
+	processLabel.Width = len(processString) + 1
+	processLabel.Height = 1
+
+	ui.Render(processLabel)
+}
+
+func (cui *ConsoleUI) drawSpawnset() {
+	spawnsetString := cui.data.Spawnset
+	if len(spawnsetString) > 28 {
+		spawnsetString = spawnsetString[:25] + "..."
+	}
+	// Pad to the widest name so a shorter one overwrites it.
+	spawnsetString = fmt.Sprintf("%28s", spawnsetString)
+
+	spawnsetLabel := ui.NewParagraph(spawnsetString)
+	spawnsetLabel.TextFgColor = ui.StringToAttribute("yellow")
+	spawnsetLabel.Border = false
+	spawnsetLabel.X = ui.TermWidth()/2 + 33 - len(spawnsetString)
+	spawnsetLabel.Y = 23
+	spawnsetLabel.Width = len(spawnsetString) + 1
+	spawnsetLabel.Height = 1
+
+	ui.Render(spawnsetLabel)
+}
+
 func (cui *ConsoleUI) drawOnlineStatus() {
 	var onlineLabelText string
 	var color ui.Attribute
@@ -300,6 +431,21 @@ func (cui *ConsoleUI) drawRecording() {
 	case StatusGameSubmitted:
 		recordingLabel.TextFgColor = ui.StringToAttribute("bold, yellow")
 		recordingLabel.Text = "[[ Game Submitted ]]"
+	case StatusAwaitingStats:
+		recordingLabel.TextFgColor = ui.StringToAttribute("yellow")
+		recordingLabel.Text = " [[ Loading stats ]]"
+	case StatusSubmitting:
+		recordingLabel.TextFgColor = ui.StringToAttribute("yellow")
+		recordingLabel.Text = "[[ Submitting... ]] "
+	case StatusSubmitFailed:
+		recordingLabel.TextFgColor = ui.StringToAttribute("bold, red")
+		recordingLabel.Text = "[[ Submit failed ]] "
+	case StatusNotSubmitted:
+		recordingLabel.TextFgColor = ui.StringToAttribute("red")
+		recordingLabel.Text = "[[ Not submitted ]] "
+	case StatusSubmitRetrying:
+		recordingLabel.TextFgColor = ui.StringToAttribute("red")
+		recordingLabel.Text = " [[ Retrying... ]]  "
 	}
 	recordingLabel.Border = false
 	recordingLabel.X = ui.TermWidth()/2 - len(recordingLabel.Text)/2
@@ -317,8 +463,15 @@ func (cui *ConsoleUI) drawLeftSideStats() {
 	enemiesAliveString := fmt.Sprintf("Enemies Alive:  %d", cui.data.EnemiesAlive)
 	enemiesKilledString := fmt.Sprintf("Enemies Killed: %d", cui.data.EnemiesKilled)
 	accuracyString := fmt.Sprintf("Accuracy:       %.2f%%", cui.data.Accuracy)
+	handString := "Hand Level:     -"
+	if hand := cui.data.HandProgress; hand.Level.Valid() {
+		handString = fmt.Sprintf("Hand Level:     %d", hand.Level)
+		if hand.GemsToNext > 0 {
+			handString += fmt.Sprintf(" (%d to next)", hand.GemsToNext)
+		}
+	}
 
-	statsLeft := ui.NewParagraph(fmt.Sprintf("%v\n%v\n%v\n%v\n%v\n%v\n", timerString, daggersHitString, daggersFiredString, enemiesAliveString, enemiesKilledString, accuracyString))
+	statsLeft := ui.NewParagraph(fmt.Sprintf("%v\n%v\n%v\n%v\n%v\n%v\n%v", timerString, daggersHitString, daggersFiredString, enemiesAliveString, enemiesKilledString, accuracyString, handString))
 	statsLeft.SetX(ui.TermWidth()/2 - 34)
 	statsLeft.SetY(15)
 	statsLeft.Border = false
@@ -0,0 +1,82 @@
+package devildaggers
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// executableName is the file name of the game's executable.
+const executableName = "dd.exe"
+
+// ErrProcessNotFound is returned by a ProcessLocator when Devil Daggers is not running.
+var ErrProcessNotFound = errors.New("devil daggers process not found")
+
+// ErrInsufficientPrivileges is returned by a ProcessLocator when the game is
+// running but the client is not allowed to read its memory.
+var ErrInsufficientPrivileges = errors.New("insufficient privileges to read devil daggers memory")
+
+// ProcessLocator finds the Devil Daggers process and opens it for reading.
+type ProcessLocator interface {
+	// Locate returns a MemoryReader for the running game, or ErrProcessNotFound
+	// if the game is not running.
+	Locate() (MemoryReader, error)
+}
+
+// MemoryReader reads the memory of an attached Devil Daggers process.
+type MemoryReader interface {
+	// BaseAddress returns the address the dd.exe module is loaded at.
+	BaseAddress() uintptr
+	// ReadMemory fills buf with the process memory starting at addr.
+	ReadMemory(addr uintptr, buf []byte) error
+	// Alive reports whether the process is still running.
+	Alive() bool
+	// Close releases the resources held for the process.
+	Close() error
+}
+
+// MemoryRegion is a readable range of a process's memory.
+type MemoryRegion struct {
+	Address uintptr
+	Size    uintptr
+}
+
+// RegionLister is implemented by MemoryReaders that can enumerate the readable
+// memory of the process. It lets the __ddstats__ block be found by scanning
+// when the pointer at baseOffset no longer leads to it.
+type RegionLister interface {
+	Regions() ([]MemoryRegion, error)
+}
+
+// ProcessInfo identifies a running process that may be Devil Daggers.
+type ProcessInfo struct {
+	PID            int
+	ExecutablePath string
+}
+
+// ExecutableName returns the file name of the process's executable.
+func (p ProcessInfo) ExecutableName() string {
+	if p.ExecutablePath == "" {
+		return ""
+	}
+	return executableBase(p.ExecutablePath)
+}
+
+// ProcessEnumerator is implemented by ProcessLocators that can find every
+// running instance of the game rather than only the first one.
+type ProcessEnumerator interface {
+	// Processes lists the running processes that look like Devil Daggers.
+	Processes() ([]ProcessInfo, error)
+	// Open attaches to the process with the given PID.
+	Open(pid int) (MemoryReader, error)
+}
+
+// ProcessDescriber is implemented by MemoryReaders that know which process they read.
+type ProcessDescriber interface {
+	Process() ProcessInfo
+}
+
+// executableBase returns the last element of either a Windows or a Unix path.
+func executableBase(p string) string {
+	return path.Base(strings.ReplaceAll(p, `\`, "/"))
+}
@@ -0,0 +1,303 @@
+//go:build linux
+// +build linux
+
+package devildaggers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func defaultLocator() ProcessLocator {
+	return linuxLocator{procDir: "/proc"}
+}
+
+// linuxLocator finds Devil Daggers running under Wine or Proton by scanning procDir.
+type linuxLocator struct {
+	procDir string
+}
+
+func (l linuxLocator) Locate() (MemoryReader, error) {
+	processes, err := l.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("Locate: %w", err)
+	}
+	if len(processes) == 0 {
+		return nil, ErrProcessNotFound
+	}
+
+	reader, err := l.Open(processes[0].PID)
+	if err != nil {
+		return nil, fmt.Errorf("Locate: %w", err)
+	}
+	return reader, nil
+}
+
+// Processes returns the processes that have dd.exe loaded.
+func (l linuxLocator) Processes() ([]ProcessInfo, error) {
+	pids, err := l.findProcesses()
+	if err != nil {
+		return nil, fmt.Errorf("Processes: could not scan processes: %w", err)
+	}
+
+	var processes []ProcessInfo
+	for _, pid := range pids {
+		_, path, err := moduleBaseAddress(l.procDir, pid)
+		if err != nil {
+			// The process may have exited, or it only mentions dd.exe on its
+			// command line (e.g. a Proton launcher script) without loading it.
+			continue
+		}
+		processes = append(processes, ProcessInfo{PID: pid, ExecutablePath: path})
+	}
+
+	return processes, nil
+}
+
+// Open opens the memory of the process with the given PID.
+func (l linuxLocator) Open(pid int) (MemoryReader, error) {
+	baseAddress, path, err := moduleBaseAddress(l.procDir, pid)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+
+	_, startTime, err := readProcStat(l.procDir, pid)
+	if err != nil {
+		return nil, fmt.Errorf("Open: %w", err)
+	}
+
+	mem, err := os.Open(filepath.Join(l.procDir, strconv.Itoa(pid), "mem"))
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return nil, fmt.Errorf("Open: no permission to read memory of PID %d (check kernel.yama.ptrace_scope): %v: %w", pid, err, ErrInsufficientPrivileges)
+		}
+		return nil, fmt.Errorf("Open: could not open memory of PID %d: %w", pid, err)
+	}
+
+	return &linuxProcess{
+		pid:         pid,
+		procDir:     l.procDir,
+		path:        path,
+		baseAddress: baseAddress,
+		startTime:   startTime,
+		mem:         mem,
+	}, nil
+}
+
+// findProcesses returns the PIDs whose command line runs dd.exe.
+func (l linuxLocator) findProcesses() ([]int, error) {
+	entries, err := ioutil.ReadDir(l.procDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join(l.procDir, entry.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		if isDevilDaggersCmdline(cmdline) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, nil
+}
+
+// isDevilDaggersCmdline reports whether a NUL separated command line runs dd.exe.
+// Wine usually rewrites argv[0] to the Windows path of the executable, but when
+// started through the preloader the executable is the first argument instead.
+func isDevilDaggersCmdline(cmdline []byte) bool {
+	args := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	for i, arg := range args {
+		if i > 1 {
+			break
+		}
+		if strings.EqualFold(executableBase(string(arg)), executableName) {
+			return true
+		}
+	}
+	return false
+}
+
+// readProcStat returns the state and the start time of the process from
+// /proc/<pid>/stat. The start time is in clock ticks since boot, and tells a
+// process apart from a later one that was given the same PID.
+func readProcStat(procDir string, pid int) (byte, uint64, error) {
+	stat, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("readProcStat: could not read stat of PID %d: %w", pid, err)
+	}
+
+	// e.g. "1234 (dd.exe) S 1 1234 ...". The command name can hold spaces and
+	// parentheses, so the fields are counted from the last ')'.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, 0, fmt.Errorf("readProcStat: malformed stat of PID %d", pid)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 20 || len(fields[0]) != 1 {
+		return 0, 0, fmt.Errorf("readProcStat: malformed stat of PID %d", pid)
+	}
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("readProcStat: malformed start time of PID %d: %w", pid, err)
+	}
+
+	return fields[0][0], startTime, nil
+}
+
+// mapping is one line of /proc/<pid>/maps.
+type mapping struct {
+	start, end address
+	perms      string
+	path       string
+}
+
+// readMaps parses /proc/<pid>/maps.
+func readMaps(procDir string, pid int) ([]mapping, error) {
+	f, err := os.Open(filepath.Join(procDir, strconv.Itoa(pid), "maps"))
+	if err != nil {
+		return nil, fmt.Errorf("readMaps: could not open maps of PID %d: %w", pid, err)
+	}
+	defer f.Close()
+
+	var mappings []mapping
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// e.g. "00400000-00401000 r--p 00000000 00:1f 1234    /games/devildaggers/dd.exe"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		bounds := strings.SplitN(fields[0], "-", 2)
+		if len(bounds) != 2 {
+			continue
+		}
+		start, err := strconv.ParseUint(bounds[0], 16, 64)
+		if err != nil {
+			continue
+		}
+		end, err := strconv.ParseUint(bounds[1], 16, 64)
+		if err != nil {
+			continue
+		}
+		mappings = append(mappings, mapping{
+			start: address(start),
+			end:   address(end),
+			perms: fields[1],
+			path:  strings.Join(fields[5:], " "),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("readMaps: could not read maps of PID %d: %w", pid, err)
+	}
+
+	return mappings, nil
+}
+
+func isExecutableMapping(m mapping) bool {
+	return m.path != "" && strings.EqualFold(executableBase(m.path), executableName)
+}
+
+// moduleBaseAddress returns the lowest address dd.exe is mapped at in the
+// process, and the path it is mapped from.
+func moduleBaseAddress(procDir string, pid int) (address, string, error) {
+	mappings, err := readMaps(procDir, pid)
+	if err != nil {
+		return 0, "", fmt.Errorf("moduleBaseAddress: %w", err)
+	}
+
+	var baseAddress address
+	var path string
+	for _, m := range mappings {
+		if !isExecutableMapping(m) {
+			continue
+		}
+		if baseAddress == 0 || m.start < baseAddress {
+			baseAddress = m.start
+			path = m.path
+		}
+	}
+
+	if baseAddress == 0 {
+		return 0, "", fmt.Errorf("moduleBaseAddress: %s is not mapped in PID %d", executableName, pid)
+	}
+
+	return baseAddress, path, nil
+}
+
+// linuxProcess reads memory from /proc/<pid>/mem.
+type linuxProcess struct {
+	pid         int
+	procDir     string
+	path        string
+	baseAddress address
+	startTime   uint64
+	mem         *os.File
+}
+
+func (p *linuxProcess) BaseAddress() uintptr {
+	return uintptr(p.baseAddress)
+}
+
+func (p *linuxProcess) ReadMemory(addr uintptr, buf []byte) error {
+	_, err := p.mem.ReadAt(buf, int64(addr))
+	if err != nil {
+		return fmt.Errorf("ReadMemory: unable to read process memory at 0x%x: %w", addr, err)
+	}
+	return nil
+}
+
+// Regions returns the readable anonymous and dd.exe mappings. The many Wine
+// and system libraries mapped into the process cannot hold the block and are skipped.
+func (p *linuxProcess) Regions() ([]MemoryRegion, error) {
+	mappings, err := readMaps(p.procDir, p.pid)
+	if err != nil {
+		return nil, fmt.Errorf("Regions: %w", err)
+	}
+
+	var regions []MemoryRegion
+	for _, m := range mappings {
+		if !strings.HasPrefix(m.perms, "r") {
+			continue
+		}
+		if m.path != "" && m.path != "[heap]" && !isExecutableMapping(m) {
+			continue
+		}
+		regions = append(regions, MemoryRegion{Address: uintptr(m.start), Size: uintptr(m.end - m.start)})
+	}
+
+	return regions, nil
+}
+
+func (p *linuxProcess) Process() ProcessInfo {
+	return ProcessInfo{PID: p.pid, ExecutablePath: p.path}
+}
+
+// Alive reports whether the process opened is still running. /proc/<pid> stays
+// around while a dead process waits to be reaped, and the PID can be given to a
+// new process, so the state and start time are checked as well.
+func (p *linuxProcess) Alive() bool {
+	state, startTime, err := readProcStat(p.procDir, p.pid)
+	if err != nil {
+		return false
+	}
+	return state != 'Z' && state != 'X' && startTime == p.startTime
+}
+
+func (p *linuxProcess) Close() error {
+	return p.mem.Close()
+}
@@ -0,0 +1,86 @@
+//go:build linux
+// +build linux
+
+package devildaggers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFakeProc lays out the /proc files of a Wine process running dd.exe under
+// procDir. comm is the command name, as in the stat file.
+func writeFakeProc(t *testing.T, procDir string, pid int, comm string, state byte, startTime uint64) {
+	t.Helper()
+	dir := filepath.Join(procDir, fmt.Sprint(pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"cmdline": `C:\devildaggers\dd.exe` + "\x00",
+		"maps":    "00400000-00401000 r--p 00000000 00:1f 1234    /games/devildaggers/dd.exe\n",
+		"stat":    fmt.Sprintf("%d (%s) %c 1 %d 0 0 -1 4194560 0 0 0 0 5 2 0 0 20 0 4 0 %d 0 0\n", pid, comm, state, pid, startTime),
+		"mem":     "",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLinuxProcessAlive(t *testing.T) {
+	tests := []struct {
+		name      string
+		comm      string
+		state     byte
+		startTime uint64
+		want      bool
+	}{
+		{"running", "dd.exe", 'S', 9000, true},
+		{"command name with a parenthesis", "dd) (x", 'R', 9000, true},
+		{"zombie", "dd.exe", 'Z', 9000, false},
+		{"dead", "dd.exe", 'X', 9000, false},
+		{"PID reused", "dd.exe", 'S', 9500, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procDir := t.TempDir()
+			writeFakeProc(t, procDir, 1234, tt.comm, 'S', 9000)
+			l := linuxLocator{procDir: procDir}
+			r, err := l.Open(1234)
+			if err != nil {
+				t.Fatalf("Open() = %v", err)
+			}
+			defer r.Close()
+			if !r.Alive() {
+				t.Fatal("Alive() = false straight after Open")
+			}
+
+			writeFakeProc(t, procDir, 1234, tt.comm, tt.state, tt.startTime)
+			if got := r.Alive(); got != tt.want {
+				t.Errorf("Alive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinuxProcessAliveAfterExit(t *testing.T) {
+	procDir := t.TempDir()
+	writeFakeProc(t, procDir, 1234, "dd.exe", 'S', 9000)
+	r, err := linuxLocator{procDir: procDir}.Open(1234)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	defer r.Close()
+
+	if err := os.RemoveAll(filepath.Join(procDir, "1234")); err != nil {
+		t.Fatal(err)
+	}
+	if r.Alive() {
+		t.Error("Alive() = true after the process exited")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package devildaggers
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func defaultLocator() ProcessLocator {
+	return unsupportedLocator{}
+}
+
+// unsupportedLocator is used on platforms without a process memory backend.
+type unsupportedLocator struct{}
+
+func (unsupportedLocator) Locate() (MemoryReader, error) {
+	return nil, fmt.Errorf("Locate: reading Devil Daggers memory is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,250 @@
+//go:build windows
+// +build windows
+
+package devildaggers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/TheTitanrain/w32"
+)
+
+const windowsCodeStillActive = 259
+
+// Values used by VirtualQueryEx, see
+// https://docs.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-memory_basic_information
+const (
+	memCommit            = 0x1000
+	pageNoAccess         = 0x01
+	pageGuard            = 0x100
+	pageReadableAnyFlags = 0x02 | 0x04 | 0x08 | 0x20 | 0x40 | 0x80
+)
+
+var (
+	modkernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procReadProcessMemory = modkernel32.NewProc("ReadProcessMemory")
+	procVirtualQueryEx    = modkernel32.NewProc("VirtualQueryEx")
+	procProcess32FirstW   = modkernel32.NewProc("Process32FirstW")
+	procProcess32NextW    = modkernel32.NewProc("Process32NextW")
+)
+
+// memoryBasicInformation mirrors MEMORY_BASIC_INFORMATION. Go's alignment of the
+// uintptr fields reproduces the padding of both the 32 and 64 bit layouts.
+type memoryBasicInformation struct {
+	BaseAddress       uintptr
+	AllocationBase    uintptr
+	AllocationProtect uint32
+	RegionSize        uintptr
+	State             uint32
+	Protect           uint32
+	Type              uint32
+}
+
+// processEntry32 mirrors PROCESSENTRY32W, which w32 does not provide.
+type processEntry32 struct {
+	Size            uint32
+	Usage           uint32
+	ProcessID       uint32
+	DefaultHeapID   uintptr
+	ModuleID        uint32
+	Threads         uint32
+	ParentProcessID uint32
+	PriClassBase    int32
+	Flags           uint32
+	ExeFile         [w32.MAX_PATH]uint16
+}
+
+func defaultLocator() ProcessLocator {
+	return windowsLocator{}
+}
+
+// windowsLocator finds Devil Daggers by its window name.
+type windowsLocator struct{}
+
+func (l windowsLocator) Locate() (MemoryReader, error) {
+	hwnd := w32.FindWindowW(nil, syscall.StringToUTF16Ptr(windowName))
+	if hwnd == 0 {
+		return nil, ErrProcessNotFound
+	}
+
+	_, pid := w32.GetWindowThreadProcessId(hwnd)
+
+	reader, err := l.Open(pid)
+	if err != nil {
+		return nil, fmt.Errorf("Locate: %w", err)
+	}
+	return reader, nil
+}
+
+// Processes returns the processes running dd.exe, and the process owning the
+// Devil Daggers window in case it was renamed.
+func (windowsLocator) Processes() ([]ProcessInfo, error) {
+	snapshot := w32.CreateToolhelp32Snapshot(w32.TH32CS_SNAPPROCESS, 0)
+	if snapshot == w32.ERROR_INVALID_HANDLE {
+		return nil, errors.New("Processes: could not snapshot processes")
+	}
+	defer w32.CloseHandle(snapshot)
+
+	var processes []ProcessInfo
+	seen := make(map[int]bool)
+	add := func(pid int, name string) {
+		if seen[pid] {
+			return
+		}
+		seen[pid] = true
+		path := name
+		if _, modulePath, err := getModule(pid); err == nil {
+			path = modulePath
+		}
+		processes = append(processes, ProcessInfo{PID: pid, ExecutablePath: path})
+	}
+
+	var pe processEntry32
+	pe.Size = uint32(unsafe.Sizeof(pe))
+	ret, _, _ := procProcess32FirstW.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&pe)))
+	for ret != 0 {
+		name := syscall.UTF16ToString(pe.ExeFile[:])
+		if strings.EqualFold(name, executableName) {
+			add(int(pe.ProcessID), name)
+		}
+		ret, _, _ = procProcess32NextW.Call(uintptr(snapshot), uintptr(unsafe.Pointer(&pe)))
+	}
+
+	if hwnd := w32.FindWindowW(nil, syscall.StringToUTF16Ptr(windowName)); hwnd != 0 {
+		_, pid := w32.GetWindowThreadProcessId(hwnd)
+		add(pid, windowName)
+	}
+
+	return processes, nil
+}
+
+// Open opens the process with the given PID for reading.
+func (windowsLocator) Open(pid int) (MemoryReader, error) {
+	hndl, err := w32.OpenProcess(w32.PROCESS_ALL_ACCESS, false, uintptr(pid))
+	if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		return nil, fmt.Errorf("Open: could not open PID %d (try running as administrator): %v: %w", pid, err, ErrInsufficientPrivileges)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Open: could not open PID %d: %w", pid, err)
+	}
+
+	baseAddress, path, err := getModule(pid)
+	if err != nil {
+		w32.CloseHandle(hndl)
+		return nil, fmt.Errorf("Open: could get base address: %w", err)
+	}
+
+	return &windowsProcess{handle: hndl, pid: pid, path: path, baseAddress: baseAddress}, nil
+}
+
+// windowsProcess reads memory from a process handle opened with OpenProcess.
+type windowsProcess struct {
+	handle      w32.HANDLE
+	pid         int
+	path        string
+	baseAddress address
+}
+
+func (p *windowsProcess) BaseAddress() uintptr {
+	return uintptr(p.baseAddress)
+}
+
+func (p *windowsProcess) ReadMemory(addr uintptr, buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+
+	// ReadProcessMemory is called directly rather than through w32, which
+	// allocates a fresh buffer for every read.
+	ret, _, _ := procReadProcessMemory.Call(
+		uintptr(p.handle),
+		addr,
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+		0,
+	)
+	if ret == 0 {
+		return errors.New("ReadMemory: unable to read process memory")
+	}
+
+	return nil
+}
+
+// Regions walks the address space of the process with VirtualQueryEx and
+// returns the committed regions that can be read.
+func (p *windowsProcess) Regions() ([]MemoryRegion, error) {
+	var regions []MemoryRegion
+	var mbi memoryBasicInformation
+	for addr := uintptr(0); ; {
+		ret, _, _ := procVirtualQueryEx.Call(
+			uintptr(p.handle),
+			addr,
+			uintptr(unsafe.Pointer(&mbi)),
+			unsafe.Sizeof(mbi),
+		)
+		if ret == 0 {
+			break
+		}
+		if mbi.State == memCommit && mbi.Protect&pageReadableAnyFlags != 0 && mbi.Protect&(pageGuard|pageNoAccess) == 0 {
+			regions = append(regions, MemoryRegion{Address: mbi.BaseAddress, Size: mbi.RegionSize})
+		}
+		next := mbi.BaseAddress + mbi.RegionSize
+		if next <= addr {
+			break
+		}
+		addr = next
+	}
+
+	if len(regions) == 0 {
+		return nil, errors.New("Regions: could not query process memory")
+	}
+
+	return regions, nil
+}
+
+func (p *windowsProcess) Process() ProcessInfo {
+	return ProcessInfo{PID: p.pid, ExecutablePath: p.path}
+}
+
+func (p *windowsProcess) Alive() bool {
+	code, err := w32.GetExitCodeProcess(p.handle)
+	if err != nil || code != windowsCodeStillActive {
+		return false
+	}
+
+	return true
+}
+
+func (p *windowsProcess) Close() error {
+	if !w32.CloseHandle(p.handle) {
+		return errors.New("Close: could not close process handle")
+	}
+	return nil
+}
+
+// getModule returns the base address and path of the main module of the process.
+func getModule(pid int) (address, string, error) {
+	var baseAddress uintptr
+	var path string
+
+	snapshot := w32.CreateToolhelp32Snapshot(w32.TH32CS_SNAPMODULE|w32.TH32CS_SNAPMODULE32, uint32(pid))
+	if snapshot != w32.ERROR_INVALID_HANDLE {
+		var me w32.MODULEENTRY32
+		me.Size = uint32(unsafe.Sizeof(me))
+		if w32.Module32First(snapshot, &me) {
+			baseAddress = uintptr(unsafe.Pointer(me.ModBaseAddr))
+			path = syscall.UTF16ToString(me.SzExePath[:])
+		}
+	}
+	defer w32.CloseHandle(snapshot)
+
+	if baseAddress == 0 {
+		return 0, "", fmt.Errorf("getModule: could not find base address for PID %d", pid)
+	}
+
+	return address(baseAddress), path, nil
+}
@@ -0,0 +1,116 @@
+package devildaggers
+
+import (
+	"errors"
+	"time"
+)
+
+// ConnectionState is the stage the persistent connection to the game is in.
+type ConnectionState int
+
+const (
+	// StateSearching is when the game is not running, or could not be opened.
+	StateSearching ConnectionState = iota
+	// StateAttaching is when the game was found and the __ddstats__ block is being resolved.
+	StateAttaching
+	// StateAttached is when the game is being read.
+	StateAttached
+	// StateLost is when an attached game exited or could no longer be read.
+	StateLost
+	// StateIncompatible is when the game was found but its memory cannot be read
+	// by this client, because there is no __ddstats__ block or its version is not supported.
+	StateIncompatible
+)
+
+var connectionStateNames = [...]string{"searching", "attaching", "attached", "lost", "incompatible"}
+
+func (s ConnectionState) String() string {
+	if s < 0 || int(s) >= len(connectionStateNames) {
+		return "unknown"
+	}
+	return connectionStateNames[s]
+}
+
+const (
+	// minReconnectBackoff is the first delay before trying to attach again.
+	minReconnectBackoff = 250 * time.Millisecond
+	// maxReconnectBackoff caps the delay between attempts while the game is absent.
+	maxReconnectBackoff = 5 * time.Second
+)
+
+var errProcessExited = errors.New("devil daggers process exited")
+
+// ErrPlayerMismatch is returned by RefreshData when the game is no longer logged
+// in as the player the ProcessSelector pins. The persistent connection then
+// detaches and looks for a process logged in as that player.
+var ErrPlayerMismatch = errors.New("devil daggers is logged in as another player than the selected one")
+
+// ConnectionStatus is the state of the persistent connection and, for failed
+// states, why it is in that state.
+type ConnectionStatus struct {
+	State ConnectionState
+	// Err is why the last attempt failed. It is nil when nothing went wrong.
+	Err error
+	// Since is when the connection entered the state.
+	Since time.Time
+}
+
+// Reason returns a short explanation of Err suitable for display, or an empty
+// string if there is none.
+func (s ConnectionStatus) Reason() string {
+	var versionErr *UnsupportedVersionError
+	switch {
+	case s.Err == nil:
+		return ""
+	case errors.Is(s.Err, ErrInsufficientPrivileges):
+		return "insufficient privileges"
+	case errors.Is(s.Err, ErrBlockNotFound):
+		return "no __ddstats__ block"
+	case errors.As(s.Err, &versionErr):
+		return versionErr.Error()
+	case errors.Is(s.Err, errProcessExited):
+		return "game exited"
+	case errors.Is(s.Err, ErrPlayerMismatch):
+		return "logged in as another player"
+	default:
+		return s.Err.Error()
+	}
+}
+
+// ConnectionStatus returns the current state of the persistent connection. It
+// is safe to call from any goroutine.
+func (dd *DevilDaggers) ConnectionStatus() ConnectionStatus {
+	status, _ := dd.connStatus.Load().(ConnectionStatus)
+	return status
+}
+
+// setConnectionStatus records a new state and, when it differs from the current
+// one, publishes an EventConnectionChanged.
+func (dd *DevilDaggers) setConnectionStatus(state ConnectionState, err error) {
+	current := dd.ConnectionStatus()
+	if current.State == state && errorText(current.Err) == errorText(err) && !current.Since.IsZero() {
+		return
+	}
+	status := ConnectionStatus{State: state, Err: err, Since: time.Now()}
+	dd.connStatus.Store(status)
+	dd.events.publish([]Event{{Type: EventConnectionChanged, WallTime: status.Since, Connection: status}})
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// nextBackoff doubles the delay between attempts up to maxReconnectBackoff.
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff < minReconnectBackoff {
+		return minReconnectBackoff
+	}
+	backoff *= 2
+	if backoff > maxReconnectBackoff {
+		return maxReconnectBackoff
+	}
+	return backoff
+}
@@ -1,34 +1,10 @@
 package devildaggers
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"unsafe"
-
-	"github.com/TheTitanrain/w32"
-)
-
-const dataSize = 232
-
-const (
-	skull1 = iota
-	skull2
-	spiderling
-	skull3
-	squid1
-	squid2
-	squid3
-	centipede1
-	centipede2
-	spider1
-	spider2
-	leviathan
-	orb
-	thorn
-	centipede3
-	spiderEgg
+	"time"
 )
 
 const (
@@ -50,6 +26,8 @@ const (
 	StatusOtherReplay
 )
 
+// DataBlock holds the values of the __ddstats__ block. How they are laid out in
+// memory depends on the block version, see layout.go.
 type DataBlock struct {
 	DDStatsVersion       int32
 	PlayerID             int32
@@ -66,8 +44,8 @@ type DataBlock struct {
 	GemsEaten            int32
 	TotalGems            int32
 	DaggersEaten         int32
-	PerEnemyAliveCount   [17]int16
-	PerEnemyKillCount    [17]int16
+	PerEnemyAliveCount   [enemyCountSlots]int16
+	PerEnemyKillCount    [enemyCountSlots]int16
 	IsPlayerAlive        bool
 	IsReplay             bool
 	DeathType            uint8
@@ -86,11 +64,9 @@ type DataBlock struct {
 	EnemiesAliveMax      int32
 	TimeEnemiesAliveMax  float32
 	TimeMax              float32
-	Padding1             [4]byte // cause computer science
-	StatsBase            int64   // address to stat frame array
+	StatsBase            int64 // address to stat frame array
 	StatsFramesLoaded    int32
 	StatsFinishedLoading bool
-	Padding2             [3]byte // Padding here because previous data is in a struct with a pointer.
 	StartingHandLevel    int32
 	StartingHomingCount  int32
 	StartingTime         float32
@@ -109,392 +85,182 @@ type StatsFrame struct {
 	GemsEaten          int32
 	TotalGems          int32
 	DaggersEaten       int32
-	PerEnemyAliveCount [17]int16
-	PerEnemyKillCount  [17]int16
+	PerEnemyAliveCount [enemyCountSlots]int16
+	PerEnemyKillCount  [enemyCountSlots]int16
 }
 
 // RefreshData attempts to read the Devil Daggers process memory. The data is acquired based
-// on the __ddstats__ block within the game's memory. The data is then read into the dataBlock
-// struct. The variables of this data can then be read using the various 'Get' methods.
+// on the __ddstats__ block within the game's memory. The data is then decoded using the layout
+// registered for the block's version and validated, the new stats frames are read, and the
+// result is published as the Snapshot returned by Snapshot. If the version is not supported, an
+// *UnsupportedVersionError is returned and a snapshot recording that version is published. If
+// the block fails validation even when read again, a *CorruptReadError is returned and the
+// previous snapshot stays published. If the game is not logged in as the player the
+// ProcessSelector pins, ErrPlayerMismatch is returned and nothing is published.
 func (dd *DevilDaggers) RefreshData() error {
 	if dd.connected != true {
 		return errors.New("RefreshData: connection to window lost")
 	}
 
-	buf, _, ok := w32.ReadProcessMemory(w32.HANDLE(dd.handle), uintptr(dd.ddstatsBlockAddress), unsafe.Sizeof(*dd.dataBlock))
-	if !ok {
-		return errors.New("RefreshData: unable to read process memory")
+	var prev *DataBlock
+	if dd.blockValid && dd.quarantined < quarantineLimit {
+		prev = dd.dataBlock
 	}
 
-	byteBuf := bytes.NewBuffer(make([]byte, 0, len(buf)*2))
-
-	for _, b := range buf {
-		split := make([]byte, 2)
-		binary.LittleEndian.PutUint16(split, b)
-		byteBuf.Write(split)
+	var problems []string
+	for attempt := 0; attempt <= corruptReadRetries; attempt++ {
+		err := dd.readBlock()
+		if err != nil {
+			return fmt.Errorf("RefreshData: %w", err)
+		}
+		problems = validateBlock(&dd.pendingBlock, prev, dd.knownHandStart(&dd.pendingBlock))
+		if problems == nil {
+			break
+		}
 	}
-
-	err := binary.Read(byteBuf, binary.LittleEndian, dd.dataBlock)
-	if err != nil {
-		return fmt.Errorf("RefreshData: unable to encode data block: %w", err)
+	if problems != nil {
+		dd.quarantined++
+		return fmt.Errorf("RefreshData: %w", dd.reportCorruptRead(problems))
 	}
 
-	return nil
-}
+	dd.quarantined = 0
+	dd.blockValid = true
+	*dd.dataBlock = dd.pendingBlock
 
-func (dd *DevilDaggers) refreshStatsFrame() error {
-	if dd.connected != true {
-		return nil
+	if !dd.selectedPlayerLoggedIn() {
+		return fmt.Errorf("RefreshData: %w", ErrPlayerMismatch)
 	}
 
-	framesLoaded := int(dd.dataBlock.StatsFramesLoaded)
+	frameGap := dd.checkFrameGap(time.Now())
+	// A failed frame read is not fatal: the frames are read again on the next
+	// tick, and GetStatsFramesComplete reports the snapshot as incomplete meanwhile.
+	_ = dd.refreshStatsFrame()
+	dd.recordTimeline()
 
-	dd.statsFrame = make([]StatsFrame, framesLoaded)
+	dd.publishSnapshot(frameGap)
 
-	if framesLoaded == 0 {
-		return nil
-	}
-
-	buf, _, ok := w32.ReadProcessMemory(w32.HANDLE(dd.handle), uintptr(dd.dataBlock.StatsBase), unsafe.Sizeof(dd.statsFrame[0])*uintptr(framesLoaded))
-	if !ok {
-		return errors.New("RefreshStatsFrame: unable to read process memory")
-	}
-
-	byteBuf := bytes.NewBuffer(make([]byte, 0, len(buf)*2))
+	return nil
+}
 
-	for _, b := range buf {
-		split := make([]byte, 2)
-		binary.LittleEndian.PutUint16(split, b)
-		byteBuf.Write(split)
+// readBlock reads the block and decodes it into pendingBlock, switching to the
+// layout of the block's version if it changed.
+func (dd *DevilDaggers) readBlock() error {
+	layout := dd.layout
+	if layout == nil {
+		layout = currentBlockLayout
 	}
 
-	err := binary.Read(byteBuf, binary.LittleEndian, dd.statsFrame)
+	dd.blockBuf = growBuffer(dd.blockBuf, layout.size)
+	err := dd.reader.ReadMemory(uintptr(dd.ddstatsBlockAddress), dd.blockBuf)
 	if err != nil {
-		return fmt.Errorf("RefreshStatsFrame: unable to encode data block: %w", err)
+		return fmt.Errorf("readBlock: unable to read process memory: %w", err)
 	}
-
-	return nil
-
-}
-
-func (dd *DevilDaggers) GetDDStatsVersion() int32 {
-	return dd.dataBlock.DDStatsVersion
-}
-
-func (dd *DevilDaggers) GetPlayerID() int32 {
-	return dd.dataBlock.PlayerID
-}
-
-func (dd *DevilDaggers) GetPlayerName() string {
-	return byteArrayToString(&dd.dataBlock.UserName)
-}
-
-func (dd *DevilDaggers) GetTime() float32 {
-	return dd.dataBlock.StartingTime + dd.dataBlock.Time
-}
-
-func (dd *DevilDaggers) GetGemsCollected() int32 {
-	return dd.GetStartingGemOffset() + dd.dataBlock.GemsCollected
-}
-
-func (dd *DevilDaggers) GetKills() int32 {
-	return dd.dataBlock.Kills
-}
-
-func (dd *DevilDaggers) GetDaggersFired() int32 {
-	return dd.dataBlock.DaggersFired
-}
-
-func (dd *DevilDaggers) GetDaggersHit() int32 {
-	return dd.dataBlock.DaggersHit
-}
-
-func (dd *DevilDaggers) GetAccuracy() float32 {
-	if dd.dataBlock.DaggersFired == 0 {
-		return 0.0
+	dd.record(sessionBlock, uintptr(dd.ddstatsBlockAddress), dd.blockBuf)
+
+	version := int32(binary.LittleEndian.Uint32(dd.blockBuf))
+	if version != layout.version {
+		layout, err = lookupBlockLayout(version)
+		if err != nil {
+			if current := dd.Snapshot(); current == nil || current.unsupportedVersion != version {
+				dd.snapshot.Store(&Snapshot{unsupportedVersion: version, takenAt: time.Now()})
+			}
+			return fmt.Errorf("readBlock: %w", err)
+		}
+		dd.layout = layout
+		// The block is read again as the new layout may be larger.
+		return dd.readBlock()
 	}
-	return float32(dd.dataBlock.DaggersHit) / float32(dd.dataBlock.DaggersFired) * 100
-}
-
-func (dd *DevilDaggers) GetEnemiesAlive() int32 {
-	return dd.dataBlock.EnemiesAlive
-}
-
-func (dd *DevilDaggers) GetLevelGems() int32 {
-	return dd.dataBlock.LevelGems
-}
-
-func (dd *DevilDaggers) GetHomingDaggers() int32 {
-	return dd.dataBlock.HomingDaggers
-}
-
-func (dd *DevilDaggers) GetGemsDespawned() int32 {
-	return dd.dataBlock.GemsDespawned
-}
-
-func (dd *DevilDaggers) GetGemsEaten() int32 {
-	return dd.dataBlock.GemsEaten
-}
-
-func (dd *DevilDaggers) GetTotalGems() int32 {
-	return dd.GetStartingGemOffset() + dd.dataBlock.TotalGems
-}
-
-func (dd *DevilDaggers) GetDaggersEaten() int32 {
-	return dd.dataBlock.DaggersEaten
-}
-
-func (dd *DevilDaggers) GetSkull1Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[skull1]
-}
-
-func (dd *DevilDaggers) GetSkull2Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[skull2]
-}
-
-func (dd *DevilDaggers) GetSpiderlingAlive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[spiderling]
-}
-
-func (dd *DevilDaggers) GetSkull3Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[skull3]
-}
-
-func (dd *DevilDaggers) GetSquid1Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[squid1]
-}
-
-func (dd *DevilDaggers) GetSquid2Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[squid2]
-}
-
-func (dd *DevilDaggers) GetSquid3Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[squid3]
-}
-
-func (dd *DevilDaggers) GetCentipede1Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[centipede1]
-}
-
-func (dd *DevilDaggers) GetCentipede2Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[centipede2]
-}
 
-func (dd *DevilDaggers) GetSpider1Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[spider1]
-}
-
-func (dd *DevilDaggers) GetSpider2Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[spider2]
-}
-
-func (dd *DevilDaggers) GetLeviathanAlive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[leviathan]
-}
-
-func (dd *DevilDaggers) GetOrbAlive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[orb]
-}
-
-func (dd *DevilDaggers) GetThornAlive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[thorn]
-}
-
-func (dd *DevilDaggers) GetCentipede3Alive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[centipede3]
-}
+	layout.decode(dd.blockBuf, &dd.pendingBlock)
 
-func (dd *DevilDaggers) GetSpiderEggAlive() int16 {
-	return dd.dataBlock.PerEnemyAliveCount[spiderEgg]
-}
-
-func (dd *DevilDaggers) GetSkull1Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[skull1]
-}
-
-func (dd *DevilDaggers) GetSkull2Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[skull2]
-}
-
-func (dd *DevilDaggers) GetSpiderlingKilled() int16 {
-	return dd.dataBlock.PerEnemyKillCount[spiderling]
-}
-
-func (dd *DevilDaggers) GetSkull3Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[skull3]
-}
-
-func (dd *DevilDaggers) GetSquid1Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[squid1]
-}
-
-func (dd *DevilDaggers) GetSquid2Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[squid2]
-}
-
-func (dd *DevilDaggers) GetSquid3Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[squid3]
-}
-
-func (dd *DevilDaggers) GetCentipede1Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[centipede1]
-}
-
-func (dd *DevilDaggers) GetCentipede2Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[centipede2]
-}
-
-func (dd *DevilDaggers) GetSpider1Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[spider1]
-}
-
-func (dd *DevilDaggers) GetSpider2Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[spider2]
-}
-
-func (dd *DevilDaggers) GetLeviathanKilled() int16 {
-	return dd.dataBlock.PerEnemyKillCount[leviathan]
-}
-
-func (dd *DevilDaggers) GetOrbKilled() int16 {
-	return dd.dataBlock.PerEnemyKillCount[orb]
-}
-
-func (dd *DevilDaggers) GetThornKilled() int16 {
-	return dd.dataBlock.PerEnemyKillCount[thorn]
-}
-
-func (dd *DevilDaggers) GetCentipede3Killed() int16 {
-	return dd.dataBlock.PerEnemyKillCount[centipede3]
-}
-
-func (dd *DevilDaggers) GetSpiderEggKilled() int16 {
-	return dd.dataBlock.PerEnemyKillCount[spiderEgg]
-}
-
-func (dd *DevilDaggers) GetIsPlayerAlive() bool {
-	return dd.dataBlock.IsPlayerAlive
-}
-
-func (dd *DevilDaggers) GetIsReplay() bool {
-	return dd.dataBlock.IsReplay
-}
-
-func (dd *DevilDaggers) GetDeathType() uint8 {
-	return dd.dataBlock.DeathType
-}
-
-func (dd *DevilDaggers) GetIsInGame() bool {
-	return dd.dataBlock.IsInGame
-}
-
-func (dd *DevilDaggers) GetReplayPlayerID() int32 {
-	return dd.dataBlock.ReplayPlayerID
-}
-
-func (dd *DevilDaggers) GetReplayPlayerName() string {
-	return byteArrayToString(&dd.dataBlock.ReplayPlayerName)
-}
-
-func (dd *DevilDaggers) GetLevelHashMD5() string {
-	return fmt.Sprintf("%x", dd.dataBlock.LevelHashMD5)
-}
-
-func (dd *DevilDaggers) GetTimeLvl2() float32 {
-	return dd.dataBlock.TimeLvl2
-}
-
-func (dd *DevilDaggers) GetTimeLvl3() float32 {
-	return dd.dataBlock.TimeLvl3
-}
-
-func (dd *DevilDaggers) GetTimeLvl4() float32 {
-	return dd.dataBlock.TimeLvl4
-}
-
-func (dd *DevilDaggers) GetLeviathanDownTime() float32 {
-	return dd.dataBlock.LeviDownTime
-}
-
-func (dd *DevilDaggers) GetOrbDownTime() float32 {
-	return dd.dataBlock.OrbDownTime
-}
-
-func (dd *DevilDaggers) GetStatus() int32 {
-	return dd.dataBlock.Status
-}
-
-func (dd *DevilDaggers) GetHomingMax() int32 {
-	return dd.dataBlock.HomingMax
-}
-
-func (dd *DevilDaggers) GetHomingMaxTime() float32 {
-	return dd.dataBlock.StartingTime + dd.dataBlock.TimeHomingMax
-}
-
-func (dd *DevilDaggers) GetEnemiesAliveMax() int32 {
-	return dd.dataBlock.EnemiesAliveMax
+	return nil
 }
 
-func (dd *DevilDaggers) GetEnemiesAliveMaxTime() float32 {
-	return dd.dataBlock.StartingTime + dd.dataBlock.TimeEnemiesAliveMax
-}
+// publishSnapshot swaps in a new Snapshot if the decoded data differs from the
+// current one, and sends subscribers the events between the two. Skipping
+// unchanged ticks keeps idle polling free of allocations. The new snapshot is
+// compared with the last one published, even if the game was detached from in
+// between, so that a run started while the client was away is still noticed.
+func (dd *DevilDaggers) publishSnapshot(frameGap bool) {
+	frames := dd.statsFrame[:len(dd.statsFrame):len(dd.statsFrame)]
+	timeline := dd.timeline[:len(dd.timeline):len(dd.timeline)]
+	handStart := dd.resolveHandStart()
+	pid := dd.AttachedProcess().PID
+	current := dd.Snapshot()
+	if current != nil && current.unsupportedVersion == 0 && current.block == *dd.dataBlock &&
+		len(current.frames) == len(frames) && (len(frames) == 0 || &current.frames[0] == &frames[0]) &&
+		len(current.timeline) == len(timeline) && current.handStart == handStart && current.pid == pid {
+		return
+	}
 
-func (dd *DevilDaggers) GetTimeMax() float32 {
-	return dd.dataBlock.StartingTime + dd.dataBlock.TimeMax
-}
+	prev := dd.lastSnapshot
+	boundary := runBoundary(prev, dd.dataBlock, pid, frameGap)
+	if boundary != BoundaryNone {
+		dd.runID = newRunID(time.Now())
+	}
 
-func (dd *DevilDaggers) GetStatsFramesLoaded() int32 {
-	return dd.dataBlock.StatsFramesLoaded
+	next := newSnapshot(dd.dataBlock, frames, timeline, handStart)
+	next.pid = pid
+	next.runID = dd.runID
+	next.boundary = boundary
+	dd.snapshot.Store(next)
+	dd.lastSnapshot = next
+	if dd.events.hasSubscribers() {
+		dd.events.publish(DiffSnapshots(prev, next))
+	}
 }
 
-func (dd *DevilDaggers) GetStatsFinishedLoading() bool {
-	return dd.dataBlock.StatsFinishedLoading
+// Snapshot returns the most recently published Snapshot, or nil if Devil Daggers
+// is not connected. It is safe to call from any goroutine.
+func (dd *DevilDaggers) Snapshot() *Snapshot {
+	s, _ := dd.snapshot.Load().(*Snapshot)
+	return s
 }
 
-func (dd *DevilDaggers) GetStartingHandLevel() int32 {
-	return dd.dataBlock.StartingHandLevel
-}
+// refreshStatsFrame reads the stats frames appended since the last call. The
+// frames already read are kept unless StatsBase changed or the frame count
+// dropped, both of which mean a new run started and the array is read afresh.
+func (dd *DevilDaggers) refreshStatsFrame() error {
+	if dd.connected != true {
+		return nil
+	}
 
-func (dd *DevilDaggers) GetStartingHomingCount() int32 {
-	return dd.dataBlock.StartingHomingCount
-}
+	framesLoaded := int(dd.dataBlock.StatsFramesLoaded)
+	if framesLoaded < 0 {
+		framesLoaded = 0
+	}
 
-func (dd *DevilDaggers) GetStartingTime() float32 {
-	return dd.dataBlock.StartingTime
-}
+	if dd.dataBlock.StatsBase != dd.statsFrameBase || framesLoaded < len(dd.statsFrame) {
+		// A new slice is used so that frames handed out for the previous run stay intact.
+		dd.statsFrame = nil
+		dd.statsFrameBase = dd.dataBlock.StatsBase
+	}
 
-func (dd *DevilDaggers) GetProhibitedMods() bool {
-	return dd.dataBlock.ProhibitedMods
-}
+	framesRead := len(dd.statsFrame)
+	if framesLoaded == framesRead {
+		return nil
+	}
 
-func (dd *DevilDaggers) GetStatsFrame() ([]StatsFrame, error) {
-	err := dd.refreshStatsFrame()
+	dd.framesBuf = growBuffer(dd.framesBuf, statsFrameSize*(framesLoaded-framesRead))
+	framesAddress := uintptr(dd.statsFrameBase) + uintptr(statsFrameSize*framesRead)
+	err := dd.reader.ReadMemory(framesAddress, dd.framesBuf)
 	if err != nil {
-		return nil, fmt.Errorf("GetStatsFrame: could not refresh stats frame: %w", err)
+		return fmt.Errorf("RefreshStatsFrame: unable to read process memory: %w", err)
 	}
-	return dd.statsFrame, nil
-}
-
-func byteArrayToString(a *[32]byte) string {
-	for i, b := range a {
-		if b == 0 {
-			return string(a[:i])
+	dd.record(sessionFrames, framesAddress, dd.framesBuf)
+
+	// The frames are only kept if all of them are valid, so a torn read is read
+	// again in full on the next tick.
+	n := len(dd.statsFrame)
+	for i := 0; i < framesLoaded-framesRead; i++ {
+		var frame StatsFrame
+		decodeStatsFrame(dd.framesBuf[i*statsFrameSize:], &frame)
+		if problems := validateStatsFrame(&frame); problems != nil {
+			dd.statsFrame = dd.statsFrame[:n]
+			return fmt.Errorf("RefreshStatsFrame: %w", dd.reportCorruptRead(problems))
 		}
+		dd.statsFrame = append(dd.statsFrame, frame)
 	}
-	return string(a[:])
-}
 
-func (dd *DevilDaggers) GetStartingGemOffset() int32 {
-	switch dd.dataBlock.StartingHandLevel {
-	case 1:
-		return 0
-	case 2:
-		return 10
-	case 3:
-		return 70
-	case 4:
-		return 220
-	}
-	return 0
+	return nil
 }
@@ -0,0 +1,67 @@
+package devildaggers
+
+import "testing"
+
+// TestRefreshDataUnchangedDoesNotAllocate checks that polling a game whose block
+// did not change allocates nothing, as the client does it 60 times a second.
+func TestRefreshDataUnchangedDoesNotAllocate(t *testing.T) {
+	f := NewFakeProcess()
+	f.SetDataBlock(testBlock())
+	f.SetStatsFrames(testFrames(3))
+	dd := connectFake(t, f)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := dd.RefreshData(); err != nil {
+			t.Fatalf("RefreshData() = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("RefreshData of an unchanged block allocates %v times, want 0", allocs)
+	}
+}
+
+func BenchmarkRefreshData(b *testing.B) {
+	f := NewFakeProcess()
+	f.SetDataBlock(testBlock())
+	f.SetStatsFrames(testFrames(3))
+	dd := connectFake(b, f)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := dd.RefreshData(); err != nil {
+			b.Fatalf("RefreshData() = %v", err)
+		}
+	}
+}
+
+func BenchmarkDecodeStatsFrame(b *testing.B) {
+	buf := make([]byte, statsFrameSize)
+	want := testFrames(2)[1]
+	encodeStatsFrame(buf, &want)
+
+	var frame StatsFrame
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decodeStatsFrame(buf, &frame)
+	}
+	if frame != want {
+		b.Fatalf("decodeStatsFrame() = %+v, want %+v", frame, want)
+	}
+}
+
+func BenchmarkDecodeBlock(b *testing.B) {
+	block := testBlock()
+	buf := currentBlockLayout.encode(&block)
+
+	var decoded DataBlock
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		currentBlockLayout.decode(buf, &decoded)
+	}
+	if decoded != block {
+		b.Fatalf("decode() = %+v, want %+v", decoded, block)
+	}
+}
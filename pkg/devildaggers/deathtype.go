@@ -0,0 +1,86 @@
+package devildaggers
+
+import "fmt"
+
+// DeathType is how the player died, as stored in the DeathType field of the
+// __ddstats__ block.
+type DeathType uint8
+
+const (
+	DeathFallen DeathType = iota
+	DeathSwarmed
+	DeathImpaled
+	DeathGored
+	DeathInfested
+	DeathOpened
+	DeathPurged
+	DeathDesecrated
+	DeathSacrificed
+	DeathEviscerated
+	DeathAnnihilated
+	DeathIntoxicated
+	DeathEnvenomated
+	DeathIncarnated
+	DeathDiscarnated
+	DeathBarbed
+)
+
+// DeathUnknown is returned by ParseDeathType for values the client does not know.
+const DeathUnknown DeathType = 0xFF
+
+type deathTypeInfo struct {
+	name    string
+	enemies []EnemyType
+}
+
+// deathTypes describes every death type the client knows. Opened is caused by
+// Skull IV, which has no slot in the per-enemy count arrays and so no EnemyType.
+var deathTypes = [...]deathTypeInfo{
+	DeathFallen:      {"Fallen", nil},
+	DeathSwarmed:     {"Swarmed", []EnemyType{EnemySkull1, EnemySkull2, EnemySkull3}},
+	DeathImpaled:     {"Impaled", []EnemyType{EnemySkull2}},
+	DeathGored:       {"Gored", []EnemyType{EnemySkull3}},
+	DeathInfested:    {"Infested", []EnemyType{EnemySpiderling, EnemySpiderEgg}},
+	DeathOpened:      {"Opened", nil},
+	DeathPurged:      {"Purged", []EnemyType{EnemySquid1}},
+	DeathDesecrated:  {"Desecrated", []EnemyType{EnemySquid2}},
+	DeathSacrificed:  {"Sacrificed", []EnemyType{EnemySquid3}},
+	DeathEviscerated: {"Eviscerated", []EnemyType{EnemyCentipede}},
+	DeathAnnihilated: {"Annihilated", []EnemyType{EnemyGigapede}},
+	DeathIntoxicated: {"Intoxicated", []EnemyType{EnemySpiderling, EnemySpider1}},
+	DeathEnvenomated: {"Envenomated", []EnemyType{EnemySpider2}},
+	DeathIncarnated:  {"Incarnated", []EnemyType{EnemyLeviathan}},
+	DeathDiscarnated: {"Discarnated", []EnemyType{EnemyOrb}},
+	DeathBarbed:      {"Barbed", []EnemyType{EnemyThorn}},
+}
+
+// ParseDeathType validates a raw death type. Values the client does not know
+// return DeathUnknown and an error.
+func ParseDeathType(deathType int) (DeathType, error) {
+	if deathType < 0 || deathType >= len(deathTypes) {
+		return DeathUnknown, fmt.Errorf("ParseDeathType: no death type related to %d", deathType)
+	}
+	return DeathType(deathType), nil
+}
+
+// Valid reports whether d is a death type the client knows.
+func (d DeathType) Valid() bool {
+	return int(d) < len(deathTypes)
+}
+
+func (d DeathType) String() string {
+	if !d.Valid() {
+		return "Unknown"
+	}
+	return deathTypes[d].name
+}
+
+// Enemies returns the enemies that can cause the death. It is empty for deaths
+// not caused by an enemy, for Opened, whose enemy has no EnemyType, and for
+// unknown death types.
+func (d DeathType) Enemies() []EnemyType {
+	if !d.Valid() {
+		return nil
+	}
+	return append([]EnemyType(nil), deathTypes[d].enemies...)
+}
@@ -0,0 +1,57 @@
+package devildaggers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeathType(t *testing.T) {
+	tests := []struct {
+		raw     int
+		want    DeathType
+		name    string
+		enemies []EnemyType
+	}{
+		{0, DeathFallen, "Fallen", nil},
+		{1, DeathSwarmed, "Swarmed", []EnemyType{EnemySkull1, EnemySkull2, EnemySkull3}},
+		{2, DeathImpaled, "Impaled", []EnemyType{EnemySkull2}},
+		{3, DeathGored, "Gored", []EnemyType{EnemySkull3}},
+		{4, DeathInfested, "Infested", []EnemyType{EnemySpiderling, EnemySpiderEgg}},
+		{5, DeathOpened, "Opened", nil},
+		{6, DeathPurged, "Purged", []EnemyType{EnemySquid1}},
+		{7, DeathDesecrated, "Desecrated", []EnemyType{EnemySquid2}},
+		{8, DeathSacrificed, "Sacrificed", []EnemyType{EnemySquid3}},
+		{9, DeathEviscerated, "Eviscerated", []EnemyType{EnemyCentipede}},
+		{10, DeathAnnihilated, "Annihilated", []EnemyType{EnemyGigapede}},
+		{11, DeathIntoxicated, "Intoxicated", []EnemyType{EnemySpiderling, EnemySpider1}},
+		{12, DeathEnvenomated, "Envenomated", []EnemyType{EnemySpider2}},
+		{13, DeathIncarnated, "Incarnated", []EnemyType{EnemyLeviathan}},
+		{14, DeathDiscarnated, "Discarnated", []EnemyType{EnemyOrb}},
+		{15, DeathBarbed, "Barbed", []EnemyType{EnemyThorn}},
+		{16, DeathUnknown, "Unknown", nil},
+		{-1, DeathUnknown, "Unknown", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDeathType(tt.raw)
+			if d != tt.want || (err != nil) != (tt.want == DeathUnknown) {
+				t.Fatalf("ParseDeathType(%d) = %d, %v, want %d", tt.raw, d, err, tt.want)
+			}
+			if d.Valid() == (tt.want == DeathUnknown) {
+				t.Errorf("Valid() = %v", d.Valid())
+			}
+			if d.String() != tt.name {
+				t.Errorf("String() = %q, want %q", d, tt.name)
+			}
+			if got := d.Enemies(); !reflect.DeepEqual(got, tt.enemies) {
+				t.Errorf("Enemies() = %v, want %v", got, tt.enemies)
+			}
+		})
+	}
+
+	// Enemies returns a copy the caller may change.
+	DeathSwarmed.Enemies()[0] = EnemyOrb
+	if got := DeathSwarmed.Enemies()[0]; got != EnemySkull1 {
+		t.Errorf("Enemies()[0] = %s after changing a returned slice, want skull1", got)
+	}
+}
@@ -0,0 +1,58 @@
+package devildaggers
+
+import "encoding/binary"
+
+// statsFrameSize is the size in bytes of one StatsFrame in the game's memory.
+const statsFrameSize = 11*4 + 2*2*enemyCountSlots
+
+// decodeStatsFrame fills frame from the statsFrameSize bytes at the start of b.
+func decodeStatsFrame(b []byte, frame *StatsFrame) {
+	_ = b[statsFrameSize-1]
+	frame.GemsCollected = int32(binary.LittleEndian.Uint32(b[0:]))
+	frame.Kills = int32(binary.LittleEndian.Uint32(b[4:]))
+	frame.DaggersFired = int32(binary.LittleEndian.Uint32(b[8:]))
+	frame.DaggersHit = int32(binary.LittleEndian.Uint32(b[12:]))
+	frame.EnemiesAlive = int32(binary.LittleEndian.Uint32(b[16:]))
+	frame.LevelGems = int32(binary.LittleEndian.Uint32(b[20:]))
+	frame.HomingDaggers = int32(binary.LittleEndian.Uint32(b[24:]))
+	frame.GemsDespawned = int32(binary.LittleEndian.Uint32(b[28:]))
+	frame.GemsEaten = int32(binary.LittleEndian.Uint32(b[32:]))
+	frame.TotalGems = int32(binary.LittleEndian.Uint32(b[36:]))
+	frame.DaggersEaten = int32(binary.LittleEndian.Uint32(b[40:]))
+	for i := range frame.PerEnemyAliveCount {
+		frame.PerEnemyAliveCount[i] = int16(binary.LittleEndian.Uint16(b[44+2*i:]))
+	}
+	for i := range frame.PerEnemyKillCount {
+		frame.PerEnemyKillCount[i] = int16(binary.LittleEndian.Uint16(b[78+2*i:]))
+	}
+}
+
+// encodeStatsFrame is the inverse of decodeStatsFrame.
+func encodeStatsFrame(b []byte, frame *StatsFrame) {
+	_ = b[statsFrameSize-1]
+	binary.LittleEndian.PutUint32(b[0:], uint32(frame.GemsCollected))
+	binary.LittleEndian.PutUint32(b[4:], uint32(frame.Kills))
+	binary.LittleEndian.PutUint32(b[8:], uint32(frame.DaggersFired))
+	binary.LittleEndian.PutUint32(b[12:], uint32(frame.DaggersHit))
+	binary.LittleEndian.PutUint32(b[16:], uint32(frame.EnemiesAlive))
+	binary.LittleEndian.PutUint32(b[20:], uint32(frame.LevelGems))
+	binary.LittleEndian.PutUint32(b[24:], uint32(frame.HomingDaggers))
+	binary.LittleEndian.PutUint32(b[28:], uint32(frame.GemsDespawned))
+	binary.LittleEndian.PutUint32(b[32:], uint32(frame.GemsEaten))
+	binary.LittleEndian.PutUint32(b[36:], uint32(frame.TotalGems))
+	binary.LittleEndian.PutUint32(b[40:], uint32(frame.DaggersEaten))
+	for i, count := range frame.PerEnemyAliveCount {
+		binary.LittleEndian.PutUint16(b[44+2*i:], uint16(count))
+	}
+	for i, count := range frame.PerEnemyKillCount {
+		binary.LittleEndian.PutUint16(b[78+2*i:], uint16(count))
+	}
+}
+
+// growBuffer returns buf resized to n bytes, reusing its backing array when it is large enough.
+func growBuffer(buf []byte, n int) []byte {
+	if cap(buf) < n {
+		return make([]byte, n)
+	}
+	return buf[:n]
+}
@@ -1,13 +1,13 @@
 package devildaggers
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unsafe"
-
-	"github.com/TheTitanrain/w32"
 )
 
 const (
@@ -18,194 +18,309 @@ const (
 	persistentConnectionTickRate = time.Second / 60
 )
 
-const windowsCodeStillActive = 259
-
-var deathTypes = []string{"Fallen", "Swarmed", "Impaled", "Gored", "Infested", "Opened", "Purged",
-	"Desecrated", "Sacrificed", "Eviscerated", "Annihilated", "Intoxicated",
-	"Envenmonated", "Incarnated", "Discarnated", "Barbed"}
-
-type (
-	handle  w32.HANDLE
-	address uintptr
-)
+type address uintptr
 
 // DevilDaggers is used to connect to and read data from Devil Daggers.
 type DevilDaggers struct {
+	// timelineInterval is first so that it is 64-bit aligned for atomic access on 32-bit platforms.
+	timelineInterval    int64
 	connected           bool
-	handle              handle
-	baseAddress         address
+	locator             ProcessLocator
+	reader              MemoryReader
 	ddstatsBlockAddress address
+	scannedBlockAddress address
+	layout              *blockLayout
+	snapshot            atomic.Value // *Snapshot
+	events              eventHub
 	dataBlock           *DataBlock
+	pendingBlock        DataBlock
+	blockValid          bool
+	quarantined         int
 	statsFrame          []StatsFrame
-	errors              chan error
-	done                chan struct{}
+	statsFrameBase      int64
+	timeline            []TimelineSample
+	timelineBase        int64
+	blockBuf            []byte
+	framesBuf           []byte
+	connStatus          atomic.Value // ConnectionStatus
+	selector            atomic.Value // ProcessSelector
+	process             atomic.Value // ProcessInfo
+	recorder            atomic.Value // *SessionRecorder
+	handStartResolver   atomic.Value // *handStartResolver
+	handStart           HandStart
+	resolvedWith        *handStartResolver
+	resolvedHash        [16]byte
+	resolvedLevel       int32
+	checkedWith         *handStartResolver
+	checkedHash         [16]byte
+	checkedStart        HandStart
+	checkedKnown        bool
+	lastSnapshot        *Snapshot
+	lastRefresh         time.Time
+	runID               string
+	runMu               sync.Mutex
+	cancel              context.CancelFunc
+	stopped             chan struct{}
 }
 
 // New creates a new DDStats struct to use.
 func New() *DevilDaggers {
+	return NewWithLocator(defaultLocator())
+}
+
+// NewWithLocator creates a new DevilDaggers struct which finds and reads the game
+// through the given ProcessLocator instead of the platform's default backend.
+func NewWithLocator(locator ProcessLocator) *DevilDaggers {
 	return &DevilDaggers{
-		dataBlock:  &DataBlock{},
-		statsFrame: []StatsFrame{},
+		timelineInterval: -1,
+		locator:          locator,
+		dataBlock:        &DataBlock{},
+		statsFrame:       []StatsFrame{},
 	}
 }
 
-func (dd *DevilDaggers) StartPersistentConnection(errors chan error) {
-	if dd.done != nil {
-		close(dd.done)
+// StartPersistentConnection keeps the client attached to Devil Daggers until ctx
+// is cancelled or StopPersistentConnection is called. While the game is absent or
+// cannot be read, attempts are spaced out with an exponential backoff. Progress
+// and failures are reported through ConnectionStatus and EventConnectionChanged.
+func (dd *DevilDaggers) StartPersistentConnection(ctx context.Context) {
+	dd.runMu.Lock()
+	defer dd.runMu.Unlock()
+
+	if dd.cancel != nil {
+		dd.cancel()
+		<-dd.stopped
 	}
-	dd.done = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	dd.cancel = cancel
+	dd.stopped = stopped
+
+	dd.setConnectionStatus(StateSearching, nil)
 	go func() {
-		for {
-			select {
-			case <-time.After(persistentConnectionTickRate):
-				if !dd.connected {
-					connected, err := dd.Connect()
-					if err != nil {
-						// errors <- fmt.Errorf("StartPersistentConnection: could not connect to devil daggers: %w", err)
-						continue
-					}
-					dd.connected = connected
-					if !dd.connected {
-						continue
-					}
-				}
-				dd.connected = dd.checkConnection()
-				if dd.connected {
-					err := dd.RefreshData()
-					if err != nil {
-						errors <- fmt.Errorf("StartPersistentConnection: could not refresh data: %w", err)
-						continue
-					}
-				}
-			case <-dd.done:
-				dd.Close()
-				dd.connected = false
-				return
-			}
-		}
+		defer close(stopped)
+		dd.runPersistentConnection(ctx)
 	}()
 }
 
+// StopPersistentConnection stops the connection started by StartPersistentConnection
+// and waits for it to close the game.
 func (dd *DevilDaggers) StopPersistentConnection() {
-	if dd.done != nil {
-		close(dd.done)
+	dd.runMu.Lock()
+	defer dd.runMu.Unlock()
+
+	if dd.cancel != nil {
+		dd.cancel()
+		<-dd.stopped
+		dd.cancel = nil
+		dd.stopped = nil
 	}
 }
 
-// Connect attempts to make a connection to the Devil Daggers process.
-func (dd *DevilDaggers) Connect() (bool, error) {
-	hwnd := w32.FindWindowW(nil, syscall.StringToUTF16Ptr(windowName))
-	if hwnd == 0 {
+func (dd *DevilDaggers) runPersistentConnection(ctx context.Context) {
+	defer func() {
+		dd.Close()
 		dd.connected = false
-		return false, nil
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	backoff := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if dd.tickPersistentConnection() {
+			backoff = 0
+			timer.Reset(persistentConnectionTickRate)
+			continue
+		}
+		backoff = nextBackoff(backoff)
+		timer.Reset(backoff)
 	}
+}
 
-	_, pid := w32.GetWindowThreadProcessId(hwnd)
+// tickPersistentConnection attaches to the game if needed and refreshes the data.
+// It returns false when the next attempt should be delayed by the backoff.
+func (dd *DevilDaggers) tickPersistentConnection() bool {
+	if !dd.connected {
+		connected, err := dd.Connect()
+		switch {
+		case errors.Is(err, ErrBlockNotFound):
+			dd.setConnectionStatus(StateIncompatible, err)
+			return false
+		case err != nil:
+			dd.setConnectionStatus(StateSearching, err)
+			return false
+		case !connected:
+			dd.setConnectionStatus(StateSearching, nil)
+			return false
+		}
+	}
+
+	if !dd.checkConnection() {
+		dd.Close()
+		dd.connected = false
+		dd.setConnectionStatus(StateLost, errProcessExited)
+		return false
+	}
 
-	hndl, err := w32.OpenProcess(w32.PROCESS_ALL_ACCESS, false, uintptr(pid))
+	err := dd.RefreshData()
+	var corruptErr *CorruptReadError
+	if errors.As(err, &corruptErr) {
+		// Reported as an event; the block is read again on the next tick.
+		return true
+	}
+	if errors.Is(err, ErrPlayerMismatch) {
+		// The next attempt looks for a process logged in as the selected player.
+		dd.Close()
+		dd.connected = false
+		dd.setConnectionStatus(StateSearching, err)
+		return false
+	}
+	var versionErr *UnsupportedVersionError
+	if errors.As(err, &versionErr) {
+		// The game stays attached in case it is restarted with a supported build.
+		dd.setConnectionStatus(StateIncompatible, err)
+		return false
+	}
 	if err != nil {
+		dd.Close()
 		dd.connected = false
-		return false, fmt.Errorf("Connect: could not open process with name %q: %w", windowName, err)
+		dd.setConnectionStatus(StateLost, err)
+		return false
 	}
 
-	baseAddress, err := getBaseAddress(pid)
+	dd.setConnectionStatus(StateAttached, nil)
+	return true
+}
+
+// selectedPlayerLoggedIn reports whether the attached game is still logged in as
+// the player the ProcessSelector pins, if it pins one. The game can switch
+// accounts while running, e.g. when the Steam account changes.
+func (dd *DevilDaggers) selectedPlayerLoggedIn() bool {
+	want := dd.ProcessSelector().PlayerID
+	playerID := dd.dataBlock.PlayerID
+	return want == 0 || playerID == 0 || playerID == want
+}
+
+// Connect attempts to make a connection to the Devil Daggers process.
+func (dd *DevilDaggers) Connect() (bool, error) {
+	reader, err := dd.locate()
+	if errors.Is(err, ErrProcessNotFound) {
+		dd.connected = false
+		return false, nil
+	}
 	if err != nil {
 		dd.connected = false
-		return false, fmt.Errorf("Connect: could get base address: %w", err)
+		return false, fmt.Errorf("Connect: could not locate process: %w", err)
 	}
 
+	dd.setConnectionStatus(StateAttaching, nil)
 	dd.connected = true
-	dd.handle = handle(hndl)
-	dd.baseAddress = baseAddress
+	dd.reader = reader
+	if describer, ok := reader.(ProcessDescriber); ok {
+		dd.process.Store(describer.Process())
+	}
+	dd.statsFrame = nil
+	dd.statsFrameBase = 0
+	dd.blockValid = false
+	dd.quarantined = 0
+	dd.timeline = nil
 
 	ddstatsBlockAddress, err := dd.getDevilDaggersBlockBaseAddress()
 	if err != nil {
 		dd.connected = false
+		dd.Close()
 		return false, fmt.Errorf("Connect: could get ddstats block address: %w", err)
 	}
 
 	dd.ddstatsBlockAddress = ddstatsBlockAddress
+	dd.recordAttach()
 
 	return true, nil
 }
 
-// Close closes the handle to Devil Daggers.
+// Close closes the connection to Devil Daggers.
 func (dd *DevilDaggers) Close() {
-	w32.CloseHandle(w32.HANDLE(dd.handle))
+	dd.snapshot.Store((*Snapshot)(nil))
+	dd.process.Store(ProcessInfo{})
+	if dd.reader != nil {
+		dd.record(sessionDetach, 0, nil)
+		dd.reader.Close()
+		dd.reader = nil
+	}
 }
 
+// CheckConnection returns whether Devil Daggers is connected and has been read
+// at least once. It is safe to call from any goroutine.
 func (dd *DevilDaggers) CheckConnection() bool {
-	return dd.connected
+	return dd.Snapshot() != nil
 }
 
-// CheckConnection returns whether the DevilDaggers struct is currently connected to Devil Daggers.
+// checkConnection returns whether the attached process is still running.
 func (dd *DevilDaggers) checkConnection() bool {
-	code, err := w32.GetExitCodeProcess(w32.HANDLE(dd.handle))
-	if err != nil || code != windowsCodeStillActive {
-		return false
-	}
-
-	return true
-}
-
-func getBaseAddress(pid int) (address, error) {
-	var baseAddress uintptr
-
-	snapshot := w32.CreateToolhelp32Snapshot(w32.TH32CS_SNAPMODULE|w32.TH32CS_SNAPMODULE32, uint32(pid))
-	if snapshot != w32.ERROR_INVALID_HANDLE {
-		var me w32.MODULEENTRY32
-		me.Size = uint32(unsafe.Sizeof(me))
-		if w32.Module32First(snapshot, &me) {
-			baseAddress = uintptr(unsafe.Pointer(me.ModBaseAddr))
-		}
-	}
-	defer w32.CloseHandle(snapshot)
-
-	if baseAddress == 0 {
-		return 0, fmt.Errorf("getBaseAddress: could not find base address for PID %d", pid)
-	}
-
-	return address(baseAddress), nil
+	return dd.reader != nil && dd.reader.Alive()
 }
 
+// getDevilDaggersBlockBaseAddress finds the start of the data following the
+// '__ddstats__' header. It follows the pointer at baseOffset first, then tries the
+// address found by the last scan, and only then scans the process memory.
 func (dd *DevilDaggers) getDevilDaggersBlockBaseAddress() (address, error) {
 	if dd.connected != true {
-		return 0, errors.New("getAddressFromPointer: connection to window lost")
+		return 0, errors.New("getDevilDaggersBlockBaseAddress: connection to window lost")
 	}
 
-	pointer, err := dd.getAddressFromPointer(dd.baseAddress + baseOffset)
+	blockAddress, scanned, err := findBlock(dd.reader, dd.scannedBlockAddress)
 	if err != nil {
-		return 0, errors.New("getDevilDaggersBlockBaseAddress: could not get base pointer")
+		return 0, fmt.Errorf("getDevilDaggersBlockBaseAddress: %w", err)
+	}
+	if scanned {
+		dd.scannedBlockAddress = blockAddress
 	}
 
-	// 0xC skips the '__ddstats__' header in the memory block.
-	return pointer + 0xC, nil
+	return blockAddress + address(len(ddstatsHeader)), nil
 }
 
-func (dd *DevilDaggers) getAddressFromPointer(p address) (address, error) {
-	if dd.connected != true {
-		return 0, errors.New("getAddressFromPointer: connection to window lost")
+// findBlock returns the address of the '__ddstats__' header in the process read
+// by r, trying the base offset pointer, then lastScanned, then a scan of memory.
+// scanned reports whether the address came from a scan.
+func findBlock(r MemoryReader, lastScanned address) (blockAddress address, scanned bool, err error) {
+	pointer, err := getAddressFromPointer(r, address(r.BaseAddress())+baseOffset)
+	if err == nil && validateBlockHeader(r, pointer) == nil {
+		return pointer, false, nil
+	}
+
+	if lastScanned != 0 && validateBlockHeader(r, lastScanned) == nil {
+		return lastScanned, true, nil
 	}
 
-	buf, _, ok := w32.ReadProcessMemory(w32.HANDLE(dd.handle), uintptr(p), 8)
+	lister, ok := r.(RegionLister)
 	if !ok {
-		return 0, errors.New("GetAddressFromPointer: unable to read process memory")
+		return 0, false, fmt.Errorf("findBlock: base pointer does not lead to the block: %w", ErrBlockNotFound)
 	}
-	return toAddress(buf), nil
-}
 
-func toAddress(b []uint16) address {
-	ret := address(0)
-	for i := len(b) - 1; i >= 0; i-- {
-		ret = (ret << 16) | address(b[i])
+	regions, err := lister.Regions()
+	if err != nil {
+		return 0, false, fmt.Errorf("findBlock: could not list memory regions: %w", err)
 	}
-	return ret
-}
 
-func GetDeathTypeString(deathType int) (string, error) {
-	if deathType < 0 || deathType > len(deathTypes) {
-		return "", errors.New("GetDeathTypeString: no death type related to this int")
+	blockAddress, err = scanForBlock(r, regions)
+	if err != nil {
+		return 0, false, fmt.Errorf("findBlock: could not scan for block: %w", err)
 	}
 
-	return deathTypes[deathType], nil
+	return blockAddress, true, nil
+}
+
+func getAddressFromPointer(r MemoryReader, p address) (address, error) {
+	var buf [8]byte
+	err := r.ReadMemory(uintptr(p), buf[:])
+	if err != nil {
+		return 0, fmt.Errorf("GetAddressFromPointer: unable to read process memory: %w", err)
+	}
+	return address(binary.LittleEndian.Uint64(buf[:])), nil
 }
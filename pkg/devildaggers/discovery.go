@@ -0,0 +1,214 @@
+package devildaggers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Candidate is a running process that may be Devil Daggers, as found by Discover.
+type Candidate struct {
+	ProcessInfo
+	// DDStatsVersion is the version of the process's __ddstats__ block, or zero
+	// if no block was found.
+	DDStatsVersion int32
+	PlayerID       int32
+	PlayerName     string
+	// Err is why the process could not be fully inspected, if it could not.
+	Err error
+}
+
+// ProcessSelector pins the client to one Devil Daggers process. Fields left at
+// their zero value match any process.
+type ProcessSelector struct {
+	PID int
+	// ExecutablePath matches the full path of the executable, or only its file
+	// name if it contains no path separator. It is compared case-insensitively.
+	ExecutablePath string
+	PlayerID       int32
+}
+
+// IsZero reports whether the selector matches any process.
+func (s ProcessSelector) IsZero() bool {
+	return s == ProcessSelector{}
+}
+
+func (s ProcessSelector) String() string {
+	var parts []string
+	if s.PID != 0 {
+		parts = append(parts, fmt.Sprintf("PID %d", s.PID))
+	}
+	if s.ExecutablePath != "" {
+		parts = append(parts, s.ExecutablePath)
+	}
+	if s.PlayerID != 0 {
+		parts = append(parts, fmt.Sprintf("player %d", s.PlayerID))
+	}
+	if len(parts) == 0 {
+		return "any process"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matchesProcess checks the PID and executable path of a process. The player ID
+// can only be checked once the process is opened.
+func (s ProcessSelector) matchesProcess(info ProcessInfo) bool {
+	if s.PID != 0 && s.PID != info.PID {
+		return false
+	}
+	if s.ExecutablePath == "" {
+		return true
+	}
+	want := strings.ReplaceAll(s.ExecutablePath, `\`, "/")
+	got := strings.ReplaceAll(info.ExecutablePath, `\`, "/")
+	if !strings.Contains(want, "/") {
+		return strings.EqualFold(want, executableBase(got))
+	}
+	return strings.EqualFold(want, got)
+}
+
+// SetProcessSelector pins the client to the processes matched by sel. It takes
+// effect the next time the client attaches to the game.
+func (dd *DevilDaggers) SetProcessSelector(sel ProcessSelector) {
+	dd.selector.Store(sel)
+}
+
+// ProcessSelector returns the selector set by SetProcessSelector.
+func (dd *DevilDaggers) ProcessSelector() ProcessSelector {
+	sel, _ := dd.selector.Load().(ProcessSelector)
+	return sel
+}
+
+// AttachedProcess returns the process the client is attached to, or a zero
+// ProcessInfo if it is not attached or the backend cannot describe its process.
+// It is safe to call from any goroutine.
+func (dd *DevilDaggers) AttachedProcess() ProcessInfo {
+	info, _ := dd.process.Load().(ProcessInfo)
+	return info
+}
+
+// Discover lists every process that may be Devil Daggers, with the version of
+// its __ddstats__ block and the player it is logged in as. Processes that cannot
+// be opened or have no block are still listed, with Err set.
+func (dd *DevilDaggers) Discover() ([]Candidate, error) {
+	enumerator, ok := dd.locator.(ProcessEnumerator)
+	if !ok {
+		return nil, errors.New("Discover: the process backend cannot list processes")
+	}
+
+	processes, err := enumerator.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("Discover: could not list processes: %w", err)
+	}
+
+	candidates := make([]Candidate, 0, len(processes))
+	for _, info := range processes {
+		candidate := Candidate{ProcessInfo: info}
+		reader, err := enumerator.Open(info.PID)
+		if err != nil {
+			candidate.Err = err
+			candidates = append(candidates, candidate)
+			continue
+		}
+		candidate.DDStatsVersion, candidate.PlayerID, candidate.PlayerName, candidate.Err = readBlockIdentity(reader)
+		reader.Close()
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, nil
+}
+
+// readBlockIdentity returns the block version and the player of the process read
+// by r. The player is only known if the block version is supported.
+func readBlockIdentity(r MemoryReader) (version, playerID int32, playerName string, err error) {
+	blockAddress, _, err := findBlock(r, 0)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("readBlockIdentity: %w", err)
+	}
+	dataAddress := uintptr(blockAddress) + uintptr(len(ddstatsHeader))
+
+	var versionBuf [4]byte
+	err = r.ReadMemory(dataAddress, versionBuf[:])
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("readBlockIdentity: could not read block version: %w", err)
+	}
+	version = int32(binary.LittleEndian.Uint32(versionBuf[:]))
+
+	layout, err := lookupBlockLayout(version)
+	if err != nil {
+		return version, 0, "", fmt.Errorf("readBlockIdentity: %w", err)
+	}
+
+	buf := make([]byte, layout.size)
+	err = r.ReadMemory(dataAddress, buf)
+	if err != nil {
+		return version, 0, "", fmt.Errorf("readBlockIdentity: could not read block: %w", err)
+	}
+	var block DataBlock
+	layout.decode(buf, &block)
+
+	return version, block.PlayerID, byteArrayToString(&block.UserName), nil
+}
+
+// locate finds the process to attach to. Without a selector it is whatever the
+// locator finds first; with one, every process the locator can list is checked.
+func (dd *DevilDaggers) locate() (MemoryReader, error) {
+	sel := dd.ProcessSelector()
+	if sel.IsZero() {
+		return dd.locator.Locate()
+	}
+
+	enumerator, ok := dd.locator.(ProcessEnumerator)
+	if !ok {
+		reader, err := dd.locator.Locate()
+		if err != nil {
+			return nil, err
+		}
+		if !selectorMatchesReader(sel, reader) {
+			reader.Close()
+			return nil, ErrProcessNotFound
+		}
+		return reader, nil
+	}
+
+	processes, err := enumerator.Processes()
+	if err != nil {
+		return nil, fmt.Errorf("locate: could not list processes: %w", err)
+	}
+
+	var openErr error
+	for _, info := range processes {
+		if !sel.matchesProcess(info) {
+			continue
+		}
+		reader, err := enumerator.Open(info.PID)
+		if err != nil {
+			openErr = err
+			continue
+		}
+		if !selectorMatchesReader(sel, reader) {
+			reader.Close()
+			continue
+		}
+		return reader, nil
+	}
+
+	if openErr != nil {
+		return nil, fmt.Errorf("locate: %w", openErr)
+	}
+	return nil, ErrProcessNotFound
+}
+
+// selectorMatchesReader checks sel against an opened process. A player ID can
+// only match once the game has logged in, so until then the process is skipped.
+func selectorMatchesReader(sel ProcessSelector, r MemoryReader) bool {
+	if describer, ok := r.(ProcessDescriber); ok && !sel.matchesProcess(describer.Process()) {
+		return false
+	}
+	if sel.PlayerID == 0 {
+		return true
+	}
+	_, playerID, _, err := readBlockIdentity(r)
+	return err == nil && playerID == sel.PlayerID
+}
@@ -0,0 +1,46 @@
+package devildaggers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPinnedPlayerSwitch(t *testing.T) {
+	f := NewFakeProcess()
+	f.SetDataBlock(testBlock())
+	f.SetStatsFrames(testFrames(3))
+	dd := NewWithLocator(f)
+	dd.SetProcessSelector(ProcessSelector{PlayerID: testBlock().PlayerID})
+
+	if !dd.tickPersistentConnection() || dd.Snapshot() == nil {
+		t.Fatal("did not attach to the game logged in as the selected player")
+	}
+
+	other := testBlock()
+	other.PlayerID = 1
+	f.SetDataBlock(other)
+	if dd.tickPersistentConnection() {
+		t.Fatal("stayed attached after the game switched to another player")
+	}
+	if s := dd.Snapshot(); s != nil {
+		t.Errorf("Snapshot() of player %d published after the switch, want nil", s.GetPlayerID())
+	}
+	if status := dd.ConnectionStatus(); status.State != StateSearching || !errors.Is(status.Err, ErrPlayerMismatch) {
+		t.Errorf("ConnectionStatus() = %v, %v, want searching for the selected player", status.State, status.Err)
+	}
+	if dd.tickPersistentConnection() || dd.connected {
+		t.Error("attached again to a game logged in as another player")
+	}
+
+	loggingIn := testBlock()
+	loggingIn.PlayerID = 0
+	f.SetDataBlock(loggingIn)
+	if dd.tickPersistentConnection() {
+		t.Error("attached to a game not logged in yet")
+	}
+
+	f.SetDataBlock(testBlock())
+	if !dd.tickPersistentConnection() || dd.Snapshot().GetPlayerID() != testBlock().PlayerID {
+		t.Error("did not attach again once the game switched back to the selected player")
+	}
+}
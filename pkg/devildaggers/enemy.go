@@ -0,0 +1,164 @@
+package devildaggers
+
+// EnemyType identifies an enemy by its index in the per-enemy alive and kill
+// count arrays of the __ddstats__ block and the stats frames.
+type EnemyType int
+
+const (
+	EnemySkull1 EnemyType = iota
+	EnemySkull2
+	EnemySpiderling
+	EnemySkull3
+	EnemySquid1
+	EnemySquid2
+	EnemySquid3
+	EnemyCentipede
+	EnemyGigapede
+	EnemySpider1
+	EnemySpider2
+	EnemyLeviathan
+	EnemyOrb
+	EnemyThorn
+	EnemyGhostpede
+	EnemySpiderEgg
+	// EnemyUnnamed is the last slot of the count arrays, which the game fills
+	// but does not document.
+	EnemyUnnamed
+)
+
+// enemyCountSlots is the length of the per-enemy count arrays.
+const enemyCountSlots = 17
+
+// EnemyCategory groups enemies of the same family.
+type EnemyCategory int
+
+const (
+	CategoryOther EnemyCategory = iota
+	CategorySkull
+	CategorySquid
+	CategoryCentipede
+	CategorySpider
+	CategoryBoss
+)
+
+var enemyCategoryNames = [...]string{"other", "skull", "squid", "centipede", "spider", "boss"}
+
+func (c EnemyCategory) String() string {
+	if c < 0 || int(c) >= len(enemyCategoryNames) {
+		return "unknown"
+	}
+	return enemyCategoryNames[c]
+}
+
+type enemyInfo struct {
+	name        string
+	displayName string
+	// colour is a termui colour attribute name.
+	colour   string
+	category EnemyCategory
+}
+
+var enemies = [enemyCountSlots]enemyInfo{
+	EnemySkull1:     {"skull1", "Skull I", "white", CategorySkull},
+	EnemySkull2:     {"skull2", "Skull II", "white", CategorySkull},
+	EnemySpiderling: {"spiderling", "Spiderling", "red", CategorySpider},
+	EnemySkull3:     {"skull3", "Skull III", "yellow", CategorySkull},
+	EnemySquid1:     {"squid1", "Squid I", "yellow", CategorySquid},
+	EnemySquid2:     {"squid2", "Squid II", "yellow", CategorySquid},
+	EnemySquid3:     {"squid3", "Squid III", "yellow", CategorySquid},
+	EnemyCentipede:  {"centipede", "Centipede", "yellow", CategoryCentipede},
+	EnemyGigapede:   {"gigapede", "Gigapede", "green", CategoryCentipede},
+	EnemySpider1:    {"spider1", "Spider I", "green", CategorySpider},
+	EnemySpider2:    {"spider2", "Spider II", "green", CategorySpider},
+	EnemyLeviathan:  {"leviathan", "Leviathan", "red", CategoryBoss},
+	EnemyOrb:        {"orb", "The Orb", "red", CategoryBoss},
+	EnemyThorn:      {"thorn", "Thorn", "red", CategoryOther},
+	EnemyGhostpede:  {"ghostpede", "Ghostpede", "magenta", CategoryCentipede},
+	EnemySpiderEgg:  {"spideregg", "Spider Egg", "white", CategorySpider},
+	EnemyUnnamed:    {"unnamed", "Unnamed", "white", CategoryOther},
+}
+
+// EnemyTypes lists every enemy type in count array order.
+var EnemyTypes = func() []EnemyType {
+	types := make([]EnemyType, enemyCountSlots)
+	for i := range types {
+		types[i] = EnemyType(i)
+	}
+	return types
+}()
+
+func (e EnemyType) valid() bool {
+	return e >= 0 && e < enemyCountSlots
+}
+
+// String returns a short identifier for the enemy, suitable for keys and exports.
+func (e EnemyType) String() string {
+	if !e.valid() {
+		return "unknown"
+	}
+	return enemies[e].name
+}
+
+// DisplayName returns the enemy's name as shown in the game.
+func (e EnemyType) DisplayName() string {
+	if !e.valid() {
+		return "Unknown"
+	}
+	return enemies[e].displayName
+}
+
+// Colour returns the termui colour attribute used to draw the enemy.
+func (e EnemyType) Colour() string {
+	if !e.valid() {
+		return "white"
+	}
+	return enemies[e].colour
+}
+
+// Category returns the family the enemy belongs to.
+func (e EnemyType) Category() EnemyCategory {
+	if !e.valid() {
+		return CategoryOther
+	}
+	return enemies[e].category
+}
+
+// EnemyCounts maps enemy types to a count of that enemy.
+type EnemyCounts map[EnemyType]int16
+
+func newEnemyCounts(counts *[enemyCountSlots]int16) EnemyCounts {
+	m := make(EnemyCounts, enemyCountSlots)
+	for i, count := range counts {
+		m[EnemyType(i)] = count
+	}
+	return m
+}
+
+// ByCategory sums the counts of each enemy category.
+func (c EnemyCounts) ByCategory() map[EnemyCategory]int {
+	m := make(map[EnemyCategory]int)
+	for e, count := range c {
+		m[e.Category()] += int(count)
+	}
+	return m
+}
+
+// GetEnemiesAliveByType returns how many of each enemy are alive.
+func (s *Snapshot) GetEnemiesAliveByType() EnemyCounts {
+	return newEnemyCounts(&s.block.PerEnemyAliveCount)
+}
+
+// GetEnemiesKilledByType returns how many of each enemy have been killed.
+func (s *Snapshot) GetEnemiesKilledByType() EnemyCounts {
+	return newEnemyCounts(&s.block.PerEnemyKillCount)
+}
+
+// EnemiesAliveByType returns how many of each enemy were alive in the frame.
+func (f *StatsFrame) EnemiesAliveByType() EnemyCounts {
+	return newEnemyCounts(&f.PerEnemyAliveCount)
+}
+
+// EnemiesKilledByType returns how many of each enemy had been killed in the frame.
+func (f *StatsFrame) EnemiesKilledByType() EnemyCounts {
+	return newEnemyCounts(&f.PerEnemyKillCount)
+}
@@ -0,0 +1,69 @@
+package devildaggers
+
+import "testing"
+
+func TestEnemyType(t *testing.T) {
+	tests := []struct {
+		enemy       EnemyType
+		name        string
+		displayName string
+		colour      string
+		category    EnemyCategory
+	}{
+		{EnemySkull1, "skull1", "Skull I", "white", CategorySkull},
+		{EnemySkull2, "skull2", "Skull II", "white", CategorySkull},
+		{EnemySpiderling, "spiderling", "Spiderling", "red", CategorySpider},
+		{EnemySkull3, "skull3", "Skull III", "yellow", CategorySkull},
+		{EnemySquid1, "squid1", "Squid I", "yellow", CategorySquid},
+		{EnemySquid2, "squid2", "Squid II", "yellow", CategorySquid},
+		{EnemySquid3, "squid3", "Squid III", "yellow", CategorySquid},
+		{EnemyCentipede, "centipede", "Centipede", "yellow", CategoryCentipede},
+		{EnemyGigapede, "gigapede", "Gigapede", "green", CategoryCentipede},
+		{EnemySpider1, "spider1", "Spider I", "green", CategorySpider},
+		{EnemySpider2, "spider2", "Spider II", "green", CategorySpider},
+		{EnemyLeviathan, "leviathan", "Leviathan", "red", CategoryBoss},
+		{EnemyOrb, "orb", "The Orb", "red", CategoryBoss},
+		{EnemyThorn, "thorn", "Thorn", "red", CategoryOther},
+		{EnemyGhostpede, "ghostpede", "Ghostpede", "magenta", CategoryCentipede},
+		{EnemySpiderEgg, "spideregg", "Spider Egg", "white", CategorySpider},
+		{EnemyUnnamed, "unnamed", "Unnamed", "white", CategoryOther},
+		{-1, "unknown", "Unknown", "white", CategoryOther},
+		{enemyCountSlots, "unknown", "Unknown", "white", CategoryOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.enemy
+			if e.String() != tt.name || e.DisplayName() != tt.displayName || e.Colour() != tt.colour || e.Category() != tt.category {
+				t.Errorf("enemy %d = %s, %q, %s, %s, want %s, %q, %s, %s", int(e), e, e.DisplayName(), e.Colour(), e.Category(),
+					tt.name, tt.displayName, tt.colour, tt.category)
+			}
+		})
+	}
+
+	if len(EnemyTypes) != enemyCountSlots {
+		t.Fatalf("len(EnemyTypes) = %d, want %d", len(EnemyTypes), enemyCountSlots)
+	}
+	for i, e := range EnemyTypes {
+		if int(e) != i {
+			t.Errorf("EnemyTypes[%d] = %d, want count array order", i, int(e))
+		}
+	}
+}
+
+func TestEnemyCountsByCategory(t *testing.T) {
+	var counts [enemyCountSlots]int16
+	counts[EnemySkull1] = 10
+	counts[EnemySkull3] = 2
+	counts[EnemySpiderling] = 4
+	counts[EnemySpiderEgg] = 1
+	counts[EnemyGhostpede] = 1
+	counts[EnemyOrb] = 1
+
+	got := newEnemyCounts(&counts).ByCategory()
+	want := map[EnemyCategory]int{CategorySkull: 12, CategorySpider: 5, CategoryCentipede: 1, CategoryBoss: 1, CategorySquid: 0, CategoryOther: 0}
+	for category, n := range want {
+		if got[category] != n {
+			t.Errorf("ByCategory()[%s] = %d, want %d", category, got[category], n)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package devildaggers
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a game moment detected between two snapshots.
+type EventType int
+
+const (
+	// EventRunStarted is when the player starts a run, including restarts
+	// between two reads and runs already started in a relaunched game.
+	EventRunStarted EventType = iota
+	// EventHandLevelReached is when the hand reaches level 2, 3 or 4.
+	EventHandLevelReached
+	// EventLeviathanDown is when the Leviathan is killed.
+	EventLeviathanDown
+	// EventOrbDown is when the Orb is killed.
+	EventOrbDown
+	// EventHomingPeak is when the homing dagger count starts falling from a peak.
+	EventHomingPeak
+	// EventEnemiesAlivePeak is when the number of enemies alive starts falling from a peak.
+	EventEnemiesAlivePeak
+	// EventDied is when the player dies, in a run or in a replay.
+	EventDied
+	// EventReplayStarted is when a replay starts playing. The replay of the run
+	// just played continues that run, so it does not start one.
+	EventReplayStarted
+	// EventStatsFinishedLoading is when the game has finished loading the stats of a run.
+	EventStatsFinishedLoading
+	// EventReturnedToMenu is when the player goes back to the title screen or main menu.
+	EventReturnedToMenu
+	// EventConnectionChanged is when the persistent connection to the game
+	// changes state. It is not derived from snapshots.
+	EventConnectionChanged
+	// EventCorruptRead is when memory read from the game failed validation and
+	// was quarantined.
+	EventCorruptRead
+)
+
+var eventTypeNames = [...]string{"RunStarted", "HandLevelReached", "LeviathanDown", "OrbDown",
+	"HomingPeak", "EnemiesAlivePeak", "Died", "ReplayStarted", "StatsFinishedLoading", "ReturnedToMenu", "ConnectionChanged", "CorruptRead"}
+
+func (t EventType) String() string {
+	if t < 0 || int(t) >= len(eventTypeNames) {
+		return "Unknown"
+	}
+	return eventTypeNames[t]
+}
+
+// eventBufferSize is how many events a subscriber can fall behind before
+// events are dropped for it.
+const eventBufferSize = 64
+
+// Event is a game moment detected by comparing successive snapshots.
+type Event struct {
+	Type EventType
+	// GameTime is the in-game time the event happened at.
+	GameTime float32
+	// WallTime is when the snapshot revealing the event was taken.
+	WallTime time.Time
+	// HandLevel is set for EventHandLevelReached.
+	HandLevel int
+	// Count is the peak value for EventHomingPeak and EventEnemiesAlivePeak.
+	Count int32
+	// DeathType is set for EventDied.
+	DeathType DeathType
+	// Boundary is how the run was found to start, for EventRunStarted and
+	// EventReplayStarted.
+	Boundary RunBoundary
+	// Ended is the last snapshot before the run started, for EventRunStarted and
+	// EventReplayStarted. It holds the previous run, if there was one.
+	Ended *Snapshot
+	// Snapshot is the snapshot the event was detected in.
+	Snapshot *Snapshot
+	// Connection is set for EventConnectionChanged.
+	Connection ConnectionStatus
+	// Err is the *CorruptReadError for EventCorruptRead.
+	Err error
+}
+
+func isReplayStatus(status int32) bool {
+	return status == StatusOwnReplayFromLastRun || status == StatusOwnReplayFromLeaderboard || status == StatusOtherReplay
+}
+
+func isMenuStatus(status int32) bool {
+	return status == StatusTitle || status == StatusMenu
+}
+
+// DiffSnapshots returns the events that happened between prev and next. No
+// events are returned if either snapshot is missing or has an unsupported version.
+// When next starts a new run, its values are not compared with those of the run
+// prev belongs to, so only the start of the run is reported.
+func DiffSnapshots(prev, next *Snapshot) []Event {
+	if prev == nil || next == nil || prev.unsupportedVersion != 0 || next.unsupportedVersion != 0 {
+		return nil
+	}
+
+	var events []Event
+	add := func(e Event) {
+		e.WallTime = next.takenAt
+		e.Snapshot = next
+		if e.GameTime == 0 {
+			e.GameTime = next.time
+		}
+		events = append(events, e)
+	}
+
+	p, n := &prev.block, &next.block
+
+	if next.boundary != BoundaryNone {
+		if isReplayStatus(n.Status) {
+			add(Event{Type: EventReplayStarted, Boundary: next.boundary, Ended: prev})
+		} else {
+			add(Event{Type: EventRunStarted, Boundary: next.boundary, Ended: prev})
+		}
+	}
+
+	if next.boundary == BoundaryNone && (n.IsInGame || n.Status == StatusDead) {
+		levelTimes := [...]struct {
+			level      int
+			prev, next float32
+		}{{2, p.TimeLvl2, n.TimeLvl2}, {3, p.TimeLvl3, n.TimeLvl3}, {4, p.TimeLvl4, n.TimeLvl4}}
+		for _, lt := range levelTimes {
+			if lt.prev == 0 && lt.next != 0 {
+				add(Event{Type: EventHandLevelReached, HandLevel: lt.level, GameTime: lt.next})
+			}
+		}
+		if p.LeviDownTime == 0 && n.LeviDownTime != 0 {
+			add(Event{Type: EventLeviathanDown, GameTime: n.LeviDownTime})
+		}
+		if p.OrbDownTime == 0 && n.OrbDownTime != 0 {
+			add(Event{Type: EventOrbDown, GameTime: n.OrbDownTime})
+		}
+		// A peak is only known once the count starts falling from its maximum.
+		if p.HomingMax > 0 && p.HomingDaggers == p.HomingMax && n.HomingDaggers < p.HomingDaggers {
+			add(Event{Type: EventHomingPeak, Count: p.HomingMax, GameTime: next.homingMaxTime})
+		}
+		if p.EnemiesAliveMax > 0 && p.EnemiesAlive == p.EnemiesAliveMax && n.EnemiesAlive < p.EnemiesAlive {
+			add(Event{Type: EventEnemiesAlivePeak, Count: p.EnemiesAliveMax, GameTime: next.enemiesAliveMaxTime})
+		}
+		if p.IsPlayerAlive && !n.IsPlayerAlive {
+			add(Event{Type: EventDied, DeathType: DeathType(n.DeathType)})
+		}
+	}
+
+	if !p.StatsFinishedLoading && n.StatsFinishedLoading {
+		add(Event{Type: EventStatsFinishedLoading, GameTime: next.timeMax})
+	}
+	if !isMenuStatus(p.Status) && isMenuStatus(n.Status) {
+		add(Event{Type: EventReturnedToMenu})
+	}
+
+	return events
+}
+
+// eventHub fans events out to subscribers.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// Subscribe returns a channel that receives game events as they are detected,
+// and a function that ends the subscription and closes the channel. Events are
+// dropped for a subscriber that falls too far behind, so a slow consumer
+// cannot stall reading the game.
+func (dd *DevilDaggers) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	dd.events.mu.Lock()
+	if dd.events.subs == nil {
+		dd.events.subs = make(map[chan Event]struct{})
+	}
+	dd.events.subs[ch] = struct{}{}
+	dd.events.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			dd.events.mu.Lock()
+			delete(dd.events.subs, ch)
+			dd.events.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (h *eventHub) hasSubscribers() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs) > 0
+}
+
+func (h *eventHub) publish(events []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		for _, e := range events {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
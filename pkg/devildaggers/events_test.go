@@ -0,0 +1,66 @@
+package devildaggers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     func(b *DataBlock)
+		next     func(b *DataBlock)
+		boundary RunBoundary
+		want     []EventType
+	}{
+		{"nothing happened", func(b *DataBlock) {}, func(b *DataBlock) {}, BoundaryNone, nil},
+		{"run started", func(b *DataBlock) { b.Status = StatusLobby }, func(b *DataBlock) {}, BoundaryStatus, []EventType{EventRunStarted}},
+		{"replay started", func(b *DataBlock) { b.Status = StatusMenu }, func(b *DataBlock) { b.Status = StatusOtherReplay }, BoundaryStatus, []EventType{EventReplayStarted}},
+		{"hand levels reached", func(b *DataBlock) { b.TimeLvl2 = 0 }, func(b *DataBlock) { b.TimeLvl3 = 12 }, BoundaryNone, []EventType{EventHandLevelReached, EventHandLevelReached}},
+		{"leviathan and orb down", func(b *DataBlock) {}, func(b *DataBlock) { b.LeviDownTime, b.OrbDownTime = 12, 12.4 }, BoundaryNone, []EventType{EventLeviathanDown, EventOrbDown}},
+		{"homing peak", func(b *DataBlock) {}, func(b *DataBlock) { b.HomingDaggers = 1 }, BoundaryNone, []EventType{EventHomingPeak}},
+		{"enemies alive peak", func(b *DataBlock) { b.EnemiesAlive = 9 }, func(b *DataBlock) { b.EnemiesAlive = 8 }, BoundaryNone, []EventType{EventEnemiesAlivePeak}},
+		{"died", func(b *DataBlock) {}, func(b *DataBlock) {
+			b.Status, b.IsPlayerAlive, b.DeathType = StatusDead, false, uint8(DeathEviscerated)
+		}, BoundaryNone, []EventType{EventDied}},
+		{"stats loaded and back to the menu", func(b *DataBlock) { b.Status = StatusDead }, func(b *DataBlock) {
+			b.Status, b.IsInGame, b.StatsFinishedLoading = StatusMenu, false, true
+		}, BoundaryNone, []EventType{EventStatsFinishedLoading, EventReturnedToMenu}},
+		{"restart at a peak", func(b *DataBlock) { b.EnemiesAlive = 9 }, func(b *DataBlock) {
+			b.Time, b.TimeMax, b.HomingDaggers, b.HomingMax, b.EnemiesAlive, b.EnemiesAliveMax = 0.1, 0.1, 0, 0, 0, 0
+			b.TimeLvl2 = 0
+		}, BoundaryRestart, []EventType{EventRunStarted}},
+		{"restart after dying", func(b *DataBlock) {
+			b.Status, b.IsPlayerAlive = StatusDead, true
+		}, func(b *DataBlock) {
+			b.IsPlayerAlive, b.HomingDaggers, b.LeviDownTime, b.TimeLvl3 = false, 0, 5, 7
+		}, BoundaryRestart, []EventType{EventRunStarted}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, n := testBlock(), testBlock()
+			tt.prev(&p)
+			tt.next(&n)
+			prev := newSnapshot(&p, nil, nil, HandStart{})
+			next := newSnapshot(&n, nil, nil, HandStart{})
+			next.boundary = tt.boundary
+
+			var got []EventType
+			for _, e := range DiffSnapshots(prev, next) {
+				got = append(got, e.Type)
+				if e.Snapshot != next {
+					t.Errorf("%s event carries another snapshot", e.Type)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSnapshots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	b := testBlock()
+	s := newSnapshot(&b, nil, nil, HandStart{})
+	if events := DiffSnapshots(nil, s); events != nil {
+		t.Errorf("DiffSnapshots() without a previous snapshot = %v, want none", events)
+	}
+}
@@ -0,0 +1,202 @@
+package devildaggers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Addresses the FakeProcess maps its memory at.
+const (
+	FakeBaseAddress        = 0x00400000
+	FakeBlockAddress       = 0x10000000
+	FakeStatsFramesAddress = 0x20000000
+)
+
+// How the FakeProcess describes itself to ProcessEnumerator and ProcessDescriber callers.
+const (
+	// FakeProcessID is the PID of a FakeProcess until SetProcessID is called.
+	FakeProcessID      = 4242
+	FakeExecutablePath = `C:\Program Files (x86)\Steam\steamapps\common\devildaggers\dd.exe`
+)
+
+// FakeProcess is an in-memory stand-in for the Devil Daggers process. It serves
+// a byte image of the __ddstats__ block and the stats frame array laid out the
+// way the game lays them out, so scripted game states go through the same
+// decoding path as live memory. It satisfies ProcessLocator and ProcessEnumerator.
+type FakeProcess struct {
+	mu      sync.RWMutex
+	running bool
+	pid     int
+	pointer [8]byte
+	block   []byte
+	frames  []byte
+	mapped  [3]fakeRegion
+}
+
+// NewFakeProcess returns a running FakeProcess serving an empty data block of
+// the newest supported version.
+func NewFakeProcess() *FakeProcess {
+	f := &FakeProcess{running: true, pid: FakeProcessID}
+	f.SetBlockPointer(FakeBlockAddress)
+	f.SetDataBlock(DataBlock{DDStatsVersion: currentBlockLayout.version})
+	return f
+}
+
+// SetRunning sets whether the fake game is running. While it is not running,
+// Locate returns ErrProcessNotFound and open readers report it as dead.
+func (f *FakeProcess) SetRunning(running bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = running
+}
+
+// SetProcessID changes the PID of the fake game, which simulates the game being
+// relaunched: readers opened before report the old process as dead.
+func (f *FakeProcess) SetProcessID(pid int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pid = pid
+}
+
+// SetBlockPointer sets the value stored at the base offset pointer, which
+// normally leads to FakeBlockAddress. Pointing it elsewhere simulates a game
+// update that moved the pointer.
+func (f *FakeProcess) SetBlockPointer(pointer uintptr) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	binary.LittleEndian.PutUint64(f.pointer[:], uint64(pointer))
+	f.remap()
+}
+
+// SetDataBlock replaces the contents of the __ddstats__ block. The block is laid
+// out according to its DDStatsVersion, or the newest layout if the version is
+// unsupported. If StatsBase is left zero it is pointed at the fake stats frame array.
+func (f *FakeProcess) SetDataBlock(block DataBlock) {
+	if block.StatsBase == 0 {
+		block.StatsBase = FakeStatsFramesAddress
+	}
+
+	layout, err := lookupBlockLayout(block.DDStatsVersion)
+	if err != nil {
+		layout = currentBlockLayout
+	}
+
+	buf := append([]byte(ddstatsHeader), layout.encode(&block)...)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.block = buf
+	f.remap()
+}
+
+// SetStatsFrames replaces the contents of the stats frame array.
+func (f *FakeProcess) SetStatsFrames(frames []StatsFrame) {
+	buf := make([]byte, statsFrameSize*len(frames))
+	for i := range frames {
+		encodeStatsFrame(buf[i*statsFrameSize:], &frames[i])
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames = buf
+	f.remap()
+}
+
+func (f *FakeProcess) Locate() (MemoryReader, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.running {
+		return nil, ErrProcessNotFound
+	}
+	return fakeReader{f: f, pid: f.pid}, nil
+}
+
+// Processes returns the fake process while it is running.
+func (f *FakeProcess) Processes() ([]ProcessInfo, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.running {
+		return nil, nil
+	}
+	return []ProcessInfo{{PID: f.pid, ExecutablePath: FakeExecutablePath}}, nil
+}
+
+// Open attaches to the fake process if pid is its PID.
+func (f *FakeProcess) Open(pid int) (MemoryReader, error) {
+	f.mu.RLock()
+	current := f.pid
+	f.mu.RUnlock()
+	if pid != current {
+		return nil, ErrProcessNotFound
+	}
+	return f.Locate()
+}
+
+// fakeRegion is a contiguous range of memory starting at address.
+type fakeRegion struct {
+	address uintptr
+	data    []byte
+}
+
+// remap updates the regions served to readers. It must be called with mu held.
+func (f *FakeProcess) remap() {
+	f.mapped = [3]fakeRegion{
+		{address: FakeBaseAddress + baseOffset, data: f.pointer[:]},
+		{address: FakeBlockAddress, data: f.block},
+		{address: FakeStatsFramesAddress, data: f.frames},
+	}
+}
+
+type fakeReader struct {
+	f   *FakeProcess
+	pid int
+}
+
+func (r fakeReader) BaseAddress() uintptr {
+	return FakeBaseAddress
+}
+
+func (r fakeReader) ReadMemory(addr uintptr, buf []byte) error {
+	r.f.mu.RLock()
+	defer r.f.mu.RUnlock()
+
+	if !r.f.running || r.f.pid != r.pid {
+		return errors.New("ReadMemory: fake process is not running")
+	}
+
+	for _, region := range r.f.mapped {
+		if addr >= region.address && addr+uintptr(len(buf)) <= region.address+uintptr(len(region.data)) {
+			copy(buf, region.data[addr-region.address:])
+			return nil
+		}
+	}
+
+	return fmt.Errorf("ReadMemory: no memory mapped at 0x%x-0x%x", addr, addr+uintptr(len(buf)))
+}
+
+func (r fakeReader) Regions() ([]MemoryRegion, error) {
+	r.f.mu.RLock()
+	defer r.f.mu.RUnlock()
+
+	var regions []MemoryRegion
+	for _, region := range r.f.mapped {
+		regions = append(regions, MemoryRegion{Address: region.address, Size: uintptr(len(region.data))})
+	}
+	return regions, nil
+}
+
+func (r fakeReader) Process() ProcessInfo {
+	return ProcessInfo{PID: r.pid, ExecutablePath: FakeExecutablePath}
+}
+
+func (r fakeReader) Alive() bool {
+	r.f.mu.RLock()
+	defer r.f.mu.RUnlock()
+	return r.f.running && r.f.pid == r.pid
+}
+
+func (r fakeReader) Close() error {
+	return nil
+}
@@ -0,0 +1,186 @@
+package devildaggers
+
+import "testing"
+
+// testLevelHash is the spawnset hash of the blocks built by testBlock.
+var testLevelHash = [16]byte{0x56, 0x9f, 0xea, 0xd8, 0x7a, 0xbf, 0x4d, 0x30, 0xfd, 0xee, 0x4d, 0x56, 0x8b, 0x45, 0x53, 0x54}
+
+// testBlock returns a block of a run being played that passes validation.
+func testBlock() DataBlock {
+	b := DataBlock{
+		DDStatsVersion:    currentBlockLayout.version,
+		PlayerID:          21854,
+		Time:              12.5,
+		GemsCollected:     30,
+		Kills:             44,
+		DaggersFired:      800,
+		DaggersHit:        200,
+		EnemiesAlive:      7,
+		LevelGems:         30,
+		HomingDaggers:     3,
+		TotalGems:         32,
+		IsPlayerAlive:     true,
+		IsInGame:          true,
+		LevelHashMD5:      testLevelHash,
+		TimeLvl2:          10.25,
+		Status:            StatusPlaying,
+		HomingMax:         3,
+		TimeHomingMax:     11,
+		EnemiesAliveMax:   9,
+		TimeMax:           12.5,
+		StatsFramesLoaded: 3,
+		StartingHandLevel: 1,
+	}
+	copy(b.UserName[:], "xvlv")
+	b.PerEnemyAliveCount[EnemySkull1] = 5
+	b.PerEnemyKillCount[EnemySquid1] = 2
+	return b
+}
+
+// testFrames returns n stats frames whose counts grow with each frame.
+func testFrames(n int) []StatsFrame {
+	frames := make([]StatsFrame, n)
+	for i := range frames {
+		frames[i] = StatsFrame{
+			GemsCollected: int32(10 * i),
+			Kills:         int32(15 * i),
+			DaggersFired:  int32(260 * i),
+			DaggersHit:    int32(65 * i),
+			EnemiesAlive:  int32(i),
+			TotalGems:     int32(10*i + 1),
+		}
+		frames[i].PerEnemyKillCount[EnemySkull1] = int16(i)
+	}
+	return frames
+}
+
+// connectFake attaches a new DevilDaggers to f and reads it once.
+func connectFake(t testing.TB, f *FakeProcess) *DevilDaggers {
+	t.Helper()
+	dd := NewWithLocator(f)
+	connected, err := dd.Connect()
+	if err != nil || !connected {
+		t.Fatalf("Connect() = %v, %v, want true, nil", connected, err)
+	}
+	if err := dd.RefreshData(); err != nil {
+		t.Fatalf("RefreshData() = %v", err)
+	}
+	return dd
+}
+
+func TestFakeProcessRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		pointer uintptr
+		scanned bool
+	}{
+		{"base pointer", FakeBlockAddress, false},
+		{"marker scan after a wrong pointer", 0xdeadbeef, true},
+		{"marker scan after a pointer to other memory", FakeStatsFramesAddress, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeProcess()
+			f.SetBlockPointer(tt.pointer)
+			f.SetDataBlock(testBlock())
+			f.SetStatsFrames(testFrames(3))
+
+			dd := connectFake(t, f)
+			if scanned := dd.scannedBlockAddress != 0; scanned != tt.scanned {
+				t.Errorf("block found by scanning = %v, want %v", scanned, tt.scanned)
+			}
+			s := dd.Snapshot()
+			if s == nil {
+				t.Fatal("Snapshot() = nil after RefreshData")
+			}
+
+			checks := []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"GetDDStatsVersion", s.GetDDStatsVersion(), currentBlockLayout.version},
+				{"GetPlayerID", s.GetPlayerID(), int32(21854)},
+				{"GetPlayerName", s.GetPlayerName(), "xvlv"},
+				{"GetTime", s.GetTime(), float32(12.5)},
+				{"GetGemsCollected", s.GetGemsCollected(), int32(30)},
+				{"GetKills", s.GetKills(), int32(44)},
+				{"GetAccuracy", s.GetAccuracy(), float32(25)},
+				{"GetEnemiesAlive", s.GetEnemiesAlive(), int32(7)},
+				{"GetHomingDaggers", s.GetHomingDaggers(), int32(3)},
+				{"GetLevelHashMD5", s.GetLevelHashMD5(), "569fead87abf4d30fdee4d568b455354"},
+				{"GetTimeLvl2", s.GetTimeLvl2(), float32(10.25)},
+				{"GetStatus", s.GetStatus(), StatusPlaying},
+				{"GetIsInGame", s.GetIsInGame(), true},
+				{"GetEnemiesAliveMax", s.GetEnemiesAliveMax(), int32(9)},
+				{"GetStatsFramesLoaded", s.GetStatsFramesLoaded(), int32(3)},
+				{"GetStatsFramesComplete", s.GetStatsFramesComplete(), true},
+				{"GetRunBoundary", s.GetRunBoundary(), BoundaryAttached},
+				{"alive Skull I", s.GetEnemiesAliveByType()[EnemySkull1], int16(5)},
+				{"killed Squid I", s.GetEnemiesKilledByType()[EnemySquid1], int16(2)},
+			}
+			for _, c := range checks {
+				if c.got != c.want {
+					t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+				}
+			}
+
+			frames := s.GetStatsFrame()
+			want := testFrames(3)
+			if len(frames) != len(want) {
+				t.Fatalf("GetStatsFrame() has %d frames, want %d", len(frames), len(want))
+			}
+			for i := range want {
+				if frames[i] != want[i] {
+					t.Errorf("frame %d = %+v, want %+v", i, frames[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFakeProcessNewFramesAreAppended(t *testing.T) {
+	f := NewFakeProcess()
+	f.SetDataBlock(testBlock())
+	f.SetStatsFrames(testFrames(3))
+	dd := connectFake(t, f)
+	first := dd.Snapshot().GetStatsFrame()
+
+	b := testBlock()
+	b.Time, b.TimeMax, b.StatsFramesLoaded = 13.5, 13.5, 4
+	f.SetDataBlock(b)
+	f.SetStatsFrames(testFrames(4))
+	if err := dd.RefreshData(); err != nil {
+		t.Fatalf("RefreshData() = %v", err)
+	}
+
+	s := dd.Snapshot()
+	if got := len(s.GetStatsFrame()); got != 4 {
+		t.Fatalf("GetStatsFrame() has %d frames, want 4", got)
+	}
+	if s.GetStatsFrame()[3] != testFrames(4)[3] {
+		t.Errorf("appended frame = %+v, want %+v", s.GetStatsFrame()[3], testFrames(4)[3])
+	}
+	if len(first) != 3 {
+		t.Errorf("earlier snapshot's frames grew to %d", len(first))
+	}
+	if s.GetRunBoundary() != BoundaryNone || s.GetRunID() != dd.runID {
+		t.Errorf("GetRunBoundary() = %v, want the run to continue", s.GetRunBoundary())
+	}
+}
+
+func TestFakeProcessNotRunning(t *testing.T) {
+	f := NewFakeProcess()
+	f.SetRunning(false)
+	dd := NewWithLocator(f)
+	connected, err := dd.Connect()
+	if connected || err != nil {
+		t.Fatalf("Connect() = %v, %v, want false, nil", connected, err)
+	}
+
+	f.SetRunning(true)
+	dd = connectFake(t, f)
+	f.SetProcessID(FakeProcessID + 1)
+	if dd.checkConnection() {
+		t.Error("checkConnection() = true after the fake game was relaunched")
+	}
+}
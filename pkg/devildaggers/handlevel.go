@@ -0,0 +1,215 @@
+package devildaggers
+
+import "fmt"
+
+// HandLevel is the level of the player's hand, from 1 to 4. The hand levels up
+// as gems are collected.
+type HandLevel int32
+
+const (
+	HandLevel1 HandLevel = iota + 1
+	HandLevel2
+	HandLevel3
+	HandLevel4
+)
+
+// HandDaggers is what a hand fires: daggers per second while spraying, and
+// daggers per shotgun shot.
+type HandDaggers struct {
+	SprayPerSecond int32
+	PerShot        int32
+}
+
+// HandLevelInfo describes what a hand level takes to reach and what it does.
+type HandLevelInfo struct {
+	Level HandLevel
+	// Gems is the number of gems collected over a run at which the level is
+	// reached. Level 4 takes 150 gems past level 3, which the game counts as 220.
+	Gems int32
+	// HomingPerGem is how many homing daggers each gem collected adds. Homing
+	// daggers are unlocked at level 3.
+	HomingPerGem int32
+	// Daggers is what the hand fires without homing daggers.
+	Daggers HandDaggers
+	// HomingDaggers is what the hand fires while it has homing daggers, or zero
+	// below level 3.
+	HomingDaggers HandDaggers
+}
+
+var handLevels = [...]HandLevelInfo{
+	{Level: HandLevel1, Gems: 0, HomingPerGem: 0, Daggers: HandDaggers{10, 20}},
+	{Level: HandLevel2, Gems: 10, HomingPerGem: 0, Daggers: HandDaggers{20, 40}},
+	{Level: HandLevel3, Gems: 70, HomingPerGem: 1, Daggers: HandDaggers{20, 40}, HomingDaggers: HandDaggers{40, 20}},
+	{Level: HandLevel4, Gems: 220, HomingPerGem: 2, Daggers: HandDaggers{30, 60}, HomingDaggers: HandDaggers{40, 20}},
+}
+
+// Valid reports whether l is a hand level of the game.
+func (l HandLevel) Valid() bool {
+	return l >= HandLevel1 && l <= HandLevel4
+}
+
+// Info returns the thresholds and characteristics of the level. Invalid levels
+// are treated as level 1.
+func (l HandLevel) Info() HandLevelInfo {
+	if !l.Valid() {
+		return handLevels[0]
+	}
+	return handLevels[l-1]
+}
+
+func (l HandLevel) String() string {
+	return fmt.Sprintf("Level %d", int32(l))
+}
+
+// HandLevelForGems returns the level a hand is at after collecting the given
+// number of gems, counting those the run started with.
+func HandLevelForGems(gems int32) HandLevel {
+	level := HandLevel1
+	for _, info := range handLevels {
+		if gems >= info.Gems {
+			level = info.Level
+		}
+	}
+	return level
+}
+
+// HandStart is the state of the hand at the start of a run.
+type HandStart struct {
+	Level HandLevel
+	// AdditionalGems is the number of gems the run starts with on top of those
+	// Level takes, as set by the spawnset.
+	AdditionalGems int32
+}
+
+// Gems returns the gems a run starting at s has collected when the timer starts.
+// The game's gem counts do not include them.
+func (s HandStart) Gems() int32 {
+	return s.Level.Info().Gems + s.AdditionalGems
+}
+
+// HandProgress is how far a hand is on its way through the levels.
+type HandProgress struct {
+	Level HandLevel
+	// Gems is the number of gems collected, counting those the run started with.
+	Gems int32
+	// GemsToNext is how many more gems reach the next level, or 0 at level 4.
+	GemsToNext int32
+	// Fraction is the progress from the current level to the next, from 0 to 1.
+	// It is 1 at level 4.
+	Fraction float32
+}
+
+// newHandProgress returns the progress of a hand at level with gems collected.
+func newHandProgress(level HandLevel, gems int32) HandProgress {
+	if !level.Valid() {
+		level = HandLevelForGems(gems)
+	}
+	p := HandProgress{Level: level, Gems: gems, Fraction: 1}
+	if level == HandLevel4 {
+		return p
+	}
+	from, to := level.Info().Gems, (level + 1).Info().Gems
+	if gems < to {
+		p.GemsToNext = to - gems
+	}
+	p.Fraction = float32(gems-from) / float32(to-from)
+	if p.Fraction < 0 {
+		p.Fraction = 0
+	} else if p.Fraction > 1 {
+		p.Fraction = 1
+	}
+	return p
+}
+
+// handLevelAt returns the level of the hand at the given time of a run that
+// started at start, from the times levels 2 to 4 were reached. A time of 0 means
+// the level was not reached.
+func handLevelAt(start HandLevel, time float32, b *DataBlock) HandLevel {
+	level := start
+	for i, reached := range [...]float32{b.TimeLvl2, b.TimeLvl3, b.TimeLvl4} {
+		if reached != 0 && reached <= time && HandLevel(i+2) > level {
+			level = HandLevel(i + 2)
+		}
+	}
+	return level
+}
+
+// HandStartResolver returns the hand start set by the spawnset with the given
+// level hash, if the spawnset is known.
+type HandStartResolver func(levelHash [16]byte) (HandStart, bool)
+
+// handStartResolver is stored in DevilDaggers so that a nil resolver can be
+// stored in an atomic.Value and so that changes are noticed by comparing pointers.
+type handStartResolver struct {
+	resolve HandStartResolver
+}
+
+// SetHandStartResolver makes runs on spawnsets known to r start at the level and
+// with the additional gems r returns. Otherwise only the starting level the game
+// reports is known. A nil r removes the resolver.
+func (dd *DevilDaggers) SetHandStartResolver(r HandStartResolver) {
+	dd.handStartResolver.Store(&handStartResolver{resolve: r})
+}
+
+// resolveHandStart returns the hand start of the run in the current block. The
+// resolver is only called when the spawnset or the resolver changes.
+func (dd *DevilDaggers) resolveHandStart() HandStart {
+	b := dd.dataBlock
+	r, _ := dd.handStartResolver.Load().(*handStartResolver)
+	if r == dd.resolvedWith && b.LevelHashMD5 == dd.resolvedHash && b.StartingHandLevel == dd.resolvedLevel {
+		return dd.handStart
+	}
+
+	dd.handStart = HandStart{Level: HandLevel(b.StartingHandLevel)}
+	if r != nil && r.resolve != nil && b.LevelHashMD5 != [16]byte{} {
+		if start, ok := r.resolve(b.LevelHashMD5); ok {
+			dd.handStart = start
+		}
+	}
+	dd.resolvedWith, dd.resolvedHash, dd.resolvedLevel = r, b.LevelHashMD5, b.StartingHandLevel
+	return dd.handStart
+}
+
+// knownHandStart returns the hand start the resolver gives the spawnset of b, or
+// nil if there is no resolver or the spawnset is unknown to it. The resolver is
+// only called when the spawnset or the resolver changes.
+func (dd *DevilDaggers) knownHandStart(b *DataBlock) *HandStart {
+	r, _ := dd.handStartResolver.Load().(*handStartResolver)
+	if r != dd.checkedWith || b.LevelHashMD5 != dd.checkedHash {
+		dd.checkedWith, dd.checkedHash, dd.checkedKnown = r, b.LevelHashMD5, false
+		if r != nil && r.resolve != nil && b.LevelHashMD5 != [16]byte{} {
+			dd.checkedStart, dd.checkedKnown = r.resolve(b.LevelHashMD5)
+		}
+	}
+	if !dd.checkedKnown {
+		return nil
+	}
+	return &dd.checkedStart
+}
+
+// GetHandStart returns the level and additional gems the run started with.
+func (s *Snapshot) GetHandStart() HandStart {
+	return s.handStart
+}
+
+// GetHandLevel returns the level of the hand at the snapshot's time.
+func (s *Snapshot) GetHandLevel() HandLevel {
+	return handLevelAt(s.handStart.Level, s.block.Time, &s.block)
+}
+
+// GetHandProgress returns the level of the hand at the snapshot's time and the
+// progress to the next level.
+func (s *Snapshot) GetHandProgress() HandProgress {
+	return newHandProgress(s.GetHandLevel(), s.gemsCollected)
+}
+
+// GetFrameHandProgress returns the level and progress of the hand at the stats
+// frame with index i, which the game records at second i of the run. ok is false
+// if the snapshot has no frame i.
+func (s *Snapshot) GetFrameHandProgress(i int) (p HandProgress, ok bool) {
+	if i < 0 || i >= len(s.frames) {
+		return HandProgress{}, false
+	}
+	level := handLevelAt(s.handStart.Level, float32(i), &s.block)
+	return newHandProgress(level, s.handStart.Gems()+s.frames[i].GemsCollected), true
+}
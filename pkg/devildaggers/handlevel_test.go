@@ -0,0 +1,49 @@
+package devildaggers
+
+import "testing"
+
+func TestGetFrameHandProgress(t *testing.T) {
+	b := testBlock()
+	b.TimeLvl2 = 1.5
+	frames := []StatsFrame{{GemsCollected: 0}, {GemsCollected: 8}, {GemsCollected: 40}}
+	s := newSnapshot(&b, frames, nil, HandStart{Level: HandLevel1})
+
+	tests := []struct {
+		i      int
+		want   HandProgress
+		wantOK bool
+	}{
+		{-1, HandProgress{}, false},
+		{0, HandProgress{Level: HandLevel1, Gems: 0, GemsToNext: 10, Fraction: 0}, true},
+		{1, HandProgress{Level: HandLevel1, Gems: 8, GemsToNext: 2, Fraction: 0.8}, true},
+		{2, HandProgress{Level: HandLevel2, Gems: 40, GemsToNext: 30, Fraction: 0.5}, true},
+		{3, HandProgress{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := s.GetFrameHandProgress(tt.i)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("GetFrameHandProgress(%d) = %+v, %v, want %+v, %v", tt.i, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestHandLevelInfo(t *testing.T) {
+	tests := []struct {
+		level HandLevel
+		want  HandLevelInfo
+	}{
+		{HandLevel1, HandLevelInfo{Level: HandLevel1, Gems: 0, Daggers: HandDaggers{SprayPerSecond: 10, PerShot: 20}}},
+		{HandLevel2, HandLevelInfo{Level: HandLevel2, Gems: 10, Daggers: HandDaggers{SprayPerSecond: 20, PerShot: 40}}},
+		{HandLevel3, HandLevelInfo{Level: HandLevel3, Gems: 70, HomingPerGem: 1,
+			Daggers: HandDaggers{SprayPerSecond: 20, PerShot: 40}, HomingDaggers: HandDaggers{SprayPerSecond: 40, PerShot: 20}}},
+		{HandLevel4, HandLevelInfo{Level: HandLevel4, Gems: 220, HomingPerGem: 2,
+			Daggers: HandDaggers{SprayPerSecond: 30, PerShot: 60}, HomingDaggers: HandDaggers{SprayPerSecond: 40, PerShot: 20}}},
+		{0, HandLevelInfo{Level: HandLevel1, Gems: 0, Daggers: HandDaggers{SprayPerSecond: 10, PerShot: 20}}},
+		{5, HandLevelInfo{Level: HandLevel1, Gems: 0, Daggers: HandDaggers{SprayPerSecond: 10, PerShot: 20}}},
+	}
+	for _, tt := range tests {
+		if got := tt.level.Info(); got != tt.want {
+			t.Errorf("HandLevel(%d).Info() = %+v, want %+v", tt.level, got, tt.want)
+		}
+	}
+}
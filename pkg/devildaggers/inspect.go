@@ -0,0 +1,199 @@
+package devildaggers
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MemoryDump is the raw __ddstats__ block of a process along with how it was
+// found and how this client decodes it. It is meant for checking or fixing a
+// block layout after a game update.
+type MemoryDump struct {
+	Process     ProcessInfo
+	BaseAddress uintptr
+	// PointerAddress is the address of the pointer to the block, the base address
+	// plus the base offset, and PointerValue the address it holds.
+	PointerAddress uintptr
+	PointerValue   uintptr
+	// PointerErr is set if the pointer could not be read or does not lead to the
+	// block. The block is then found by scanning the process memory.
+	PointerErr error
+	// HeaderAddress is the address of the "__ddstats__" marker and Header the
+	// marker as read.
+	HeaderAddress uintptr
+	Header        []byte
+	// BlockAddress is the address of the data following the marker.
+	BlockAddress uintptr
+	Version      int32
+	// LayoutVersion is the version of the layout the block was decoded with. It
+	// differs from Version when Version is not supported, in which case the
+	// newest layout is used.
+	LayoutVersion int32
+	Block         []byte
+	Fields        []FieldDump
+	Decoded       DataBlock
+	// FirstFrame and LastFrame are the first and last stats frames the game has
+	// loaded, if any.
+	FirstFrame *FrameDump
+	LastFrame  *FrameDump
+}
+
+// FieldDump is one field of the block layout.
+type FieldDump struct {
+	Name   string
+	Offset int
+	Raw    []byte
+	// Value is the decoded value, formatted for display.
+	Value string
+}
+
+// FrameDump is one stats frame as read from memory.
+type FrameDump struct {
+	Index   int
+	Address uintptr
+	Raw     []byte
+	Fields  []FieldDump
+	Decoded StatsFrame
+}
+
+// statsFrameCounts names the int32 counts at the start of a stats frame, in
+// the order of decodeStatsFrame.
+var statsFrameCounts = [...]string{"GemsCollected", "Kills", "DaggersFired", "DaggersHit", "EnemiesAlive",
+	"LevelGems", "HomingDaggers", "GemsDespawned", "GemsEaten", "TotalGems", "DaggersEaten"}
+
+// Inspector reads the raw __ddstats__ block of the process a DevilDaggers would
+// attach to, independently of its connection.
+type Inspector struct {
+	dd          *DevilDaggers
+	reader      MemoryReader
+	lastScanned address
+}
+
+// NewInspector returns an Inspector for the processes dd attaches to, honouring
+// its process selector.
+func (dd *DevilDaggers) NewInspector() *Inspector {
+	return &Inspector{dd: dd}
+}
+
+// Inspect attaches to the game if it is not attached yet and dumps its block.
+func (in *Inspector) Inspect() (*MemoryDump, error) {
+	if in.reader != nil && !in.reader.Alive() {
+		in.Close()
+	}
+	if in.reader == nil {
+		reader, err := in.dd.locate()
+		if err != nil {
+			return nil, fmt.Errorf("Inspect: could not locate process: %w", err)
+		}
+		in.reader = reader
+		in.lastScanned = 0
+	}
+	r := in.reader
+
+	d := &MemoryDump{
+		BaseAddress:    r.BaseAddress(),
+		PointerAddress: r.BaseAddress() + baseOffset,
+	}
+	if describer, ok := r.(ProcessDescriber); ok {
+		d.Process = describer.Process()
+	}
+
+	pointer, err := getAddressFromPointer(r, address(d.PointerAddress))
+	d.PointerValue = uintptr(pointer)
+	if err == nil {
+		err = validateBlockHeader(r, pointer)
+	}
+	d.PointerErr = err
+
+	headerAddress, scanned, err := findBlock(r, in.lastScanned)
+	if err != nil {
+		return d, fmt.Errorf("Inspect: %w", err)
+	}
+	if scanned {
+		in.lastScanned = headerAddress
+	}
+	d.HeaderAddress = uintptr(headerAddress)
+	d.BlockAddress = d.HeaderAddress + uintptr(len(ddstatsHeader))
+
+	d.Header = make([]byte, len(ddstatsHeader))
+	if err := r.ReadMemory(d.HeaderAddress, d.Header); err != nil {
+		return d, fmt.Errorf("Inspect: could not read header: %w", err)
+	}
+
+	var versionBuf [4]byte
+	if err := r.ReadMemory(d.BlockAddress, versionBuf[:]); err != nil {
+		return d, fmt.Errorf("Inspect: could not read block version: %w", err)
+	}
+	d.Version = int32(binary.LittleEndian.Uint32(versionBuf[:]))
+
+	layout, err := lookupBlockLayout(d.Version)
+	if err != nil {
+		layout = currentBlockLayout
+	}
+	d.LayoutVersion = layout.version
+
+	d.Block = make([]byte, layout.size)
+	if err := r.ReadMemory(d.BlockAddress, d.Block); err != nil {
+		return d, fmt.Errorf("Inspect: could not read block: %w", err)
+	}
+	layout.decode(d.Block, &d.Decoded)
+	for _, f := range layout.fields {
+		d.Fields = append(d.Fields, FieldDump{
+			Name:   f.name,
+			Offset: f.offset,
+			Raw:    d.Block[f.offset : f.offset+f.size],
+			Value:  f.format(&d.Decoded),
+		})
+	}
+
+	loaded := int(d.Decoded.StatsFramesLoaded)
+	if loaded > 0 && loaded <= maxStatsFrames && d.Decoded.StatsBase > 0 {
+		if d.FirstFrame, err = in.readFrame(uintptr(d.Decoded.StatsBase), 0); err != nil {
+			return d, fmt.Errorf("Inspect: %w", err)
+		}
+		if d.LastFrame, err = in.readFrame(uintptr(d.Decoded.StatsBase), loaded-1); err != nil {
+			return d, fmt.Errorf("Inspect: %w", err)
+		}
+	}
+
+	return d, nil
+}
+
+func (in *Inspector) readFrame(base uintptr, i int) (*FrameDump, error) {
+	f := &FrameDump{
+		Index:   i,
+		Address: base + uintptr(i*statsFrameSize),
+		Raw:     make([]byte, statsFrameSize),
+	}
+	if err := in.reader.ReadMemory(f.Address, f.Raw); err != nil {
+		return nil, fmt.Errorf("readFrame: could not read stats frame %d: %w", i, err)
+	}
+	decodeStatsFrame(f.Raw, &f.Decoded)
+
+	for i, name := range statsFrameCounts {
+		raw := f.Raw[4*i : 4*i+4]
+		f.Fields = append(f.Fields, FieldDump{
+			Name:   name,
+			Offset: 4 * i,
+			Raw:    raw,
+			Value:  fmt.Sprint(int32(binary.LittleEndian.Uint32(raw))),
+		})
+	}
+	countsStart := 4 * len(statsFrameCounts)
+	countsEnd := countsStart + 2*enemyCountSlots
+	f.Fields = append(f.Fields,
+		FieldDump{Name: "PerEnemyAliveCount", Offset: countsStart, Raw: f.Raw[countsStart:countsEnd], Value: fmt.Sprint(f.Decoded.PerEnemyAliveCount)},
+		FieldDump{Name: "PerEnemyKillCount", Offset: countsEnd, Raw: f.Raw[countsEnd:statsFrameSize], Value: fmt.Sprint(f.Decoded.PerEnemyKillCount)},
+	)
+	return f, nil
+}
+
+// Close detaches from the process.
+func (in *Inspector) Close() error {
+	if in.reader == nil {
+		return nil
+	}
+	err := in.reader.Close()
+	in.reader = nil
+	return err
+}
@@ -0,0 +1,245 @@
+package devildaggers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// UnsupportedVersionError is returned when the __ddstats__ block has a version
+// that no registered layout can decode.
+type UnsupportedVersionError struct {
+	Version int32
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("unsupported game/ddstats block version %d", e.Version)
+}
+
+// blockField describes where one DataBlock field lives within the block.
+type blockField struct {
+	name   string
+	offset int
+	size   int
+	decode func(b []byte, block *DataBlock)
+	encode func(b []byte, block *DataBlock)
+	// format returns the decoded value of the field for display.
+	format func(block *DataBlock) string
+}
+
+// blockLayout describes one version of the __ddstats__ block, excluding the header.
+type blockLayout struct {
+	version int32
+	size    int
+	fields  []blockField
+}
+
+// blockLayouts holds every block version this client can decode, keyed by DDStatsVersion.
+var blockLayouts = map[int32]*blockLayout{}
+
+// currentBlockLayout is the newest layout and the one assumed before a version has been read.
+var currentBlockLayout *blockLayout
+
+func registerBlockLayout(layout *blockLayout) {
+	for _, f := range layout.fields {
+		if f.offset+f.size > layout.size {
+			panic(fmt.Sprintf("registerBlockLayout: field %s overruns version %d layout", f.name, layout.version))
+		}
+	}
+	blockLayouts[layout.version] = layout
+	if currentBlockLayout == nil || layout.version > currentBlockLayout.version {
+		currentBlockLayout = layout
+	}
+}
+
+func lookupBlockLayout(version int32) (*blockLayout, error) {
+	layout, ok := blockLayouts[version]
+	if !ok {
+		return nil, &UnsupportedVersionError{Version: version}
+	}
+	return layout, nil
+}
+
+// decode fills block from buf, which must hold at least layout.size bytes.
+func (layout *blockLayout) decode(buf []byte, block *DataBlock) {
+	for _, f := range layout.fields {
+		f.decode(buf[f.offset:f.offset+f.size], block)
+	}
+}
+
+// encode returns the bytes the game would hold in memory for block.
+func (layout *blockLayout) encode(block *DataBlock) []byte {
+	buf := make([]byte, layout.size)
+	for _, f := range layout.fields {
+		f.encode(buf[f.offset:f.offset+f.size], block)
+	}
+	return buf
+}
+
+func int32Field(name string, offset int, field func(*DataBlock) *int32) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   4,
+		decode: func(b []byte, block *DataBlock) { *field(block) = int32(binary.LittleEndian.Uint32(b)) },
+		encode: func(b []byte, block *DataBlock) { binary.LittleEndian.PutUint32(b, uint32(*field(block))) },
+		format: func(block *DataBlock) string { return fmt.Sprint(*field(block)) },
+	}
+}
+
+func int64Field(name string, offset int, field func(*DataBlock) *int64) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   8,
+		decode: func(b []byte, block *DataBlock) { *field(block) = int64(binary.LittleEndian.Uint64(b)) },
+		encode: func(b []byte, block *DataBlock) { binary.LittleEndian.PutUint64(b, uint64(*field(block))) },
+		format: func(block *DataBlock) string { return fmt.Sprintf("%d (0x%x)", *field(block), *field(block)) },
+	}
+}
+
+func float32Field(name string, offset int, field func(*DataBlock) *float32) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   4,
+		decode: func(b []byte, block *DataBlock) {
+			*field(block) = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		},
+		encode: func(b []byte, block *DataBlock) {
+			binary.LittleEndian.PutUint32(b, math.Float32bits(*field(block)))
+		},
+		format: func(block *DataBlock) string { return fmt.Sprint(*field(block)) },
+	}
+}
+
+func boolField(name string, offset int, field func(*DataBlock) *bool) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   1,
+		decode: func(b []byte, block *DataBlock) { *field(block) = b[0] != 0 },
+		encode: func(b []byte, block *DataBlock) {
+			if *field(block) {
+				b[0] = 1
+			}
+		},
+		format: func(block *DataBlock) string { return fmt.Sprint(*field(block)) },
+	}
+}
+
+func uint8Field(name string, offset int, field func(*DataBlock) *uint8) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   1,
+		decode: func(b []byte, block *DataBlock) { *field(block) = b[0] },
+		encode: func(b []byte, block *DataBlock) { b[0] = *field(block) },
+		format: func(block *DataBlock) string { return fmt.Sprint(*field(block)) },
+	}
+}
+
+func bytesField(name string, offset, size int, field func(*DataBlock) []byte) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   size,
+		decode: func(b []byte, block *DataBlock) { copy(field(block), b) },
+		encode: func(b []byte, block *DataBlock) { copy(b, field(block)) },
+		format: func(block *DataBlock) string { return formatBytes(field(block)) },
+	}
+}
+
+func enemyCountField(name string, offset int, field func(*DataBlock) *[enemyCountSlots]int16) blockField {
+	return blockField{
+		name:   name,
+		offset: offset,
+		size:   2 * enemyCountSlots,
+		decode: func(b []byte, block *DataBlock) {
+			counts := field(block)
+			for i := range counts {
+				counts[i] = int16(binary.LittleEndian.Uint16(b[2*i:]))
+			}
+		},
+		encode: func(b []byte, block *DataBlock) {
+			for i, count := range field(block) {
+				binary.LittleEndian.PutUint16(b[2*i:], uint16(count))
+			}
+		},
+		format: func(block *DataBlock) string { return fmt.Sprint(*field(block)) },
+	}
+}
+
+// formatBytes shows a byte field as a string if it holds a NUL-terminated
+// printable string, and as hex otherwise.
+func formatBytes(b []byte) string {
+	end := bytes.IndexByte(b, 0)
+	if end < 0 {
+		end = len(b)
+	}
+	printable := end > 0
+	for _, c := range b[:end] {
+		if c < 0x20 || c > 0x7e {
+			printable = false
+			break
+		}
+	}
+	if printable || bytes.Count(b, []byte{0}) == len(b) {
+		return fmt.Sprintf("%q", b[:end])
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+func init() {
+	// Version 1 is the block this client was originally written against. The gaps
+	// at 256 and 273 are padding added by the game's compiler.
+	registerBlockLayout(&blockLayout{
+		version: 1,
+		size:    289,
+		fields: []blockField{
+			int32Field("DDStatsVersion", 0, func(d *DataBlock) *int32 { return &d.DDStatsVersion }),
+			int32Field("PlayerID", 4, func(d *DataBlock) *int32 { return &d.PlayerID }),
+			bytesField("UserName", 8, 32, func(d *DataBlock) []byte { return d.UserName[:] }),
+			float32Field("Time", 40, func(d *DataBlock) *float32 { return &d.Time }),
+			int32Field("GemsCollected", 44, func(d *DataBlock) *int32 { return &d.GemsCollected }),
+			int32Field("Kills", 48, func(d *DataBlock) *int32 { return &d.Kills }),
+			int32Field("DaggersFired", 52, func(d *DataBlock) *int32 { return &d.DaggersFired }),
+			int32Field("DaggersHit", 56, func(d *DataBlock) *int32 { return &d.DaggersHit }),
+			int32Field("EnemiesAlive", 60, func(d *DataBlock) *int32 { return &d.EnemiesAlive }),
+			int32Field("LevelGems", 64, func(d *DataBlock) *int32 { return &d.LevelGems }),
+			int32Field("HomingDaggers", 68, func(d *DataBlock) *int32 { return &d.HomingDaggers }),
+			int32Field("GemsDespawned", 72, func(d *DataBlock) *int32 { return &d.GemsDespawned }),
+			int32Field("GemsEaten", 76, func(d *DataBlock) *int32 { return &d.GemsEaten }),
+			int32Field("TotalGems", 80, func(d *DataBlock) *int32 { return &d.TotalGems }),
+			int32Field("DaggersEaten", 84, func(d *DataBlock) *int32 { return &d.DaggersEaten }),
+			enemyCountField("PerEnemyAliveCount", 88, func(d *DataBlock) *[enemyCountSlots]int16 { return &d.PerEnemyAliveCount }),
+			enemyCountField("PerEnemyKillCount", 122, func(d *DataBlock) *[enemyCountSlots]int16 { return &d.PerEnemyKillCount }),
+			boolField("IsPlayerAlive", 156, func(d *DataBlock) *bool { return &d.IsPlayerAlive }),
+			boolField("IsReplay", 157, func(d *DataBlock) *bool { return &d.IsReplay }),
+			uint8Field("DeathType", 158, func(d *DataBlock) *uint8 { return &d.DeathType }),
+			boolField("IsInGame", 159, func(d *DataBlock) *bool { return &d.IsInGame }),
+			int32Field("ReplayPlayerID", 160, func(d *DataBlock) *int32 { return &d.ReplayPlayerID }),
+			bytesField("ReplayPlayerName", 164, 32, func(d *DataBlock) []byte { return d.ReplayPlayerName[:] }),
+			bytesField("LevelHashMD5", 196, 16, func(d *DataBlock) []byte { return d.LevelHashMD5[:] }),
+			float32Field("TimeLvl2", 212, func(d *DataBlock) *float32 { return &d.TimeLvl2 }),
+			float32Field("TimeLvl3", 216, func(d *DataBlock) *float32 { return &d.TimeLvl3 }),
+			float32Field("TimeLvl4", 220, func(d *DataBlock) *float32 { return &d.TimeLvl4 }),
+			float32Field("LeviDownTime", 224, func(d *DataBlock) *float32 { return &d.LeviDownTime }),
+			float32Field("OrbDownTime", 228, func(d *DataBlock) *float32 { return &d.OrbDownTime }),
+			int32Field("Status", 232, func(d *DataBlock) *int32 { return &d.Status }),
+			int32Field("HomingMax", 236, func(d *DataBlock) *int32 { return &d.HomingMax }),
+			float32Field("TimeHomingMax", 240, func(d *DataBlock) *float32 { return &d.TimeHomingMax }),
+			int32Field("EnemiesAliveMax", 244, func(d *DataBlock) *int32 { return &d.EnemiesAliveMax }),
+			float32Field("TimeEnemiesAliveMax", 248, func(d *DataBlock) *float32 { return &d.TimeEnemiesAliveMax }),
+			float32Field("TimeMax", 252, func(d *DataBlock) *float32 { return &d.TimeMax }),
+			int64Field("StatsBase", 260, func(d *DataBlock) *int64 { return &d.StatsBase }),
+			int32Field("StatsFramesLoaded", 268, func(d *DataBlock) *int32 { return &d.StatsFramesLoaded }),
+			boolField("StatsFinishedLoading", 272, func(d *DataBlock) *bool { return &d.StatsFinishedLoading }),
+			int32Field("StartingHandLevel", 276, func(d *DataBlock) *int32 { return &d.StartingHandLevel }),
+			int32Field("StartingHomingCount", 280, func(d *DataBlock) *int32 { return &d.StartingHomingCount }),
+			float32Field("StartingTime", 284, func(d *DataBlock) *float32 { return &d.StartingTime }),
+			boolField("ProhibitedMods", 288, func(d *DataBlock) *bool { return &d.ProhibitedMods }),
+		},
+	})
+}
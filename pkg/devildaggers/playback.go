@@ -0,0 +1,223 @@
+package devildaggers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionPlayer serves a session file written by a SessionRecorder as if it were
+// the live Devil Daggers process. The recorded reads are replayed on the
+// session's own clock, scaled by the playback speed, so the client sees the game
+// being attached, changing and exiting as it did when it was recorded. When the
+// session ends the process exits. It satisfies ProcessLocator.
+type SessionPlayer struct {
+	mu          sync.Mutex
+	records     []sessionRecord
+	speed       float64
+	start       time.Time
+	cursor      int
+	attached    bool
+	generation  int
+	baseAddress uintptr
+	pid         int
+	memory      sparseMemory
+}
+
+// NewSessionPlayer reads the session file from r and starts playing it. A speed
+// of 1 plays it in real time, 10 ten times faster.
+func NewSessionPlayer(r io.Reader, speed float64) (*SessionPlayer, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("NewSessionPlayer: invalid playback speed %v", speed)
+	}
+	records, err := readSession(r)
+	if err != nil {
+		return nil, fmt.Errorf("NewSessionPlayer: %w", err)
+	}
+	return &SessionPlayer{records: records, speed: speed, start: time.Now()}, nil
+}
+
+// Duration returns the length of the session at normal speed.
+func (p *SessionPlayer) Duration() time.Duration {
+	if len(p.records) == 0 {
+		return 0
+	}
+	return p.records[len(p.records)-1].at
+}
+
+// Done reports whether the whole session has been played.
+func (p *SessionPlayer) Done() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.advance()
+	return p.cursor == len(p.records)
+}
+
+func (p *SessionPlayer) Locate() (MemoryReader, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.advance()
+	if !p.attached {
+		return nil, ErrProcessNotFound
+	}
+	return &sessionReader{p: p, generation: p.generation}, nil
+}
+
+// advance applies the records up to the current playback position. It must be
+// called with mu held.
+func (p *SessionPlayer) advance() {
+	position := time.Duration(float64(time.Since(p.start)) * p.speed)
+	for p.cursor < len(p.records) && p.records[p.cursor].at <= position {
+		p.apply(&p.records[p.cursor])
+		p.cursor++
+		// The first block read belongs with the attach, as the client validates
+		// the block's version while attaching.
+		if p.records[p.cursor-1].kind == sessionAttach && p.cursor < len(p.records) && p.records[p.cursor].kind == sessionBlock {
+			p.apply(&p.records[p.cursor])
+			p.cursor++
+		}
+	}
+	if p.cursor == len(p.records) && p.attached {
+		p.detach()
+	}
+}
+
+func (p *SessionPlayer) apply(rec *sessionRecord) {
+	switch rec.kind {
+	case sessionAttach:
+		p.detach()
+		p.attached = true
+		if len(rec.data) >= 12 {
+			p.baseAddress = uintptr(binary.LittleEndian.Uint64(rec.data[0:]))
+			p.pid = int(binary.LittleEndian.Uint32(rec.data[8:]))
+		}
+		// The pointer and marker are rebuilt so the block is found the same way
+		// whether it was reached through the pointer or by scanning when recorded.
+		headerAddress := rec.addr - uintptr(len(ddstatsHeader))
+		var pointer [8]byte
+		binary.LittleEndian.PutUint64(pointer[:], uint64(headerAddress))
+		p.memory.write(p.baseAddress+baseOffset, pointer[:])
+		p.memory.write(headerAddress, []byte(ddstatsHeader))
+	case sessionDetach:
+		p.detach()
+	case sessionBlock, sessionFrames:
+		p.memory.write(rec.addr, rec.data)
+	}
+}
+
+func (p *SessionPlayer) detach() {
+	p.attached = false
+	p.generation++
+	p.memory = nil
+}
+
+// sessionReader reads one attachment of a SessionPlayer. It dies when the
+// recorded process was detached from.
+type sessionReader struct {
+	p          *SessionPlayer
+	generation int
+}
+
+func (r *sessionReader) BaseAddress() uintptr {
+	r.p.mu.Lock()
+	defer r.p.mu.Unlock()
+	return r.p.baseAddress
+}
+
+func (r *sessionReader) ReadMemory(addr uintptr, buf []byte) error {
+	r.p.mu.Lock()
+	defer r.p.mu.Unlock()
+	r.p.advance()
+	if !r.alive() {
+		return errors.New("ReadMemory: recorded process has exited")
+	}
+	if !r.p.memory.read(addr, buf) {
+		return fmt.Errorf("ReadMemory: no recorded memory at 0x%x-0x%x", addr, addr+uintptr(len(buf)))
+	}
+	return nil
+}
+
+func (r *sessionReader) Process() ProcessInfo {
+	r.p.mu.Lock()
+	defer r.p.mu.Unlock()
+	return ProcessInfo{PID: r.p.pid, ExecutablePath: "session playback"}
+}
+
+func (r *sessionReader) Alive() bool {
+	r.p.mu.Lock()
+	defer r.p.mu.Unlock()
+	r.p.advance()
+	return r.alive()
+}
+
+func (r *sessionReader) alive() bool {
+	return r.p.attached && r.p.generation == r.generation
+}
+
+func (r *sessionReader) Close() error {
+	return nil
+}
+
+// memorySegment is a contiguous range of known memory.
+type memorySegment struct {
+	addr uintptr
+	data []byte
+}
+
+func (s memorySegment) end() uintptr {
+	return s.addr + uintptr(len(s.data))
+}
+
+// sparseMemory is the memory known from recorded reads, as sorted segments that
+// neither overlap nor touch.
+type sparseMemory []memorySegment
+
+// write stores data at addr, merging it with the segments it overlaps or touches.
+func (m *sparseMemory) write(addr uintptr, data []byte) {
+	segments := *m
+	end := addr + uintptr(len(data))
+
+	// Find the segments [first, last) that overlap or touch [addr, end).
+	first := sort.Search(len(segments), func(i int) bool { return segments[i].end() >= addr })
+	last := first
+	for last < len(segments) && segments[last].addr <= end {
+		last++
+	}
+
+	if last-first == 1 && segments[first].addr <= addr && end <= segments[first].end() {
+		copy(segments[first].data[addr-segments[first].addr:], data)
+		return
+	}
+
+	start := addr
+	if last > first {
+		if segments[first].addr < start {
+			start = segments[first].addr
+		}
+		if segments[last-1].end() > end {
+			end = segments[last-1].end()
+		}
+	}
+	merged := memorySegment{addr: start, data: make([]byte, end-start)}
+	for _, s := range segments[first:last] {
+		copy(merged.data[s.addr-start:], s.data)
+	}
+	copy(merged.data[addr-start:], data)
+
+	result := append(segments[:first:first], merged)
+	*m = append(result, segments[last:]...)
+}
+
+// read fills buf from the memory at addr, and reports whether all of it is known.
+func (m sparseMemory) read(addr uintptr, buf []byte) bool {
+	i := sort.Search(len(m), func(i int) bool { return m[i].end() > addr })
+	if i == len(m) || m[i].addr > addr || addr+uintptr(len(buf)) > m[i].end() {
+		return false
+	}
+	copy(buf, m[i].data[addr-m[i].addr:])
+	return true
+}
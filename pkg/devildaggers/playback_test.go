@@ -0,0 +1,162 @@
+package devildaggers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	f := NewFakeProcess()
+	b := testBlock()
+	f.SetDataBlock(b)
+	f.SetStatsFrames(testFrames(3))
+
+	var buf bytes.Buffer
+	rec, err := NewSessionRecorder(&buf)
+	if err != nil {
+		t.Fatalf("NewSessionRecorder() = %v", err)
+	}
+	dd := NewWithLocator(f)
+	dd.SetSessionRecorder(rec)
+	if connected, err := dd.Connect(); !connected || err != nil {
+		t.Fatalf("Connect() = %v, %v, want true, nil", connected, err)
+	}
+
+	steps := []func(f *FakeProcess, b *DataBlock){
+		func(f *FakeProcess, b *DataBlock) {},
+		func(f *FakeProcess, b *DataBlock) {
+			b.Time, b.TimeMax, b.Kills, b.StatsFramesLoaded = 13.5, 13.5, 50, 4
+			f.SetStatsFrames(testFrames(4))
+		},
+		// An unchanged read is not recorded.
+		func(f *FakeProcess, b *DataBlock) {},
+		func(f *FakeProcess, b *DataBlock) {
+			b.Status, b.IsPlayerAlive, b.DeathType = StatusDead, false, uint8(DeathSwarmed)
+		},
+	}
+	var recorded []*Snapshot
+	for i, step := range steps {
+		step(f, &b)
+		f.SetDataBlock(b)
+		if err := dd.RefreshData(); err != nil {
+			t.Fatalf("RefreshData() at step %d = %v", i, err)
+		}
+		if i != 2 {
+			recorded = append(recorded, dd.Snapshot())
+		}
+	}
+	dd.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+
+	p, err := NewSessionPlayer(&buf, 1)
+	if err != nil {
+		t.Fatalf("NewSessionPlayer() = %v", err)
+	}
+	// Each read is played an hour after the one before, and the clock is moved to
+	// the end of each recorded step: its block read and the frame reads after it.
+	var stepEnds []time.Duration
+	for i := range p.records {
+		p.records[i].at = time.Duration(i) * time.Hour
+		if i > 1 && p.records[i].kind != sessionFrames {
+			stepEnds = append(stepEnds, p.records[i-1].at)
+		}
+	}
+	if len(stepEnds) != len(recorded) || p.records[0].kind != sessionAttach || p.records[len(p.records)-1].kind != sessionDetach {
+		t.Fatalf("session of %d steps, want an attach, %d steps and a detach", len(stepEnds), len(recorded))
+	}
+	seek := func(at time.Duration) {
+		p.mu.Lock()
+		p.start = time.Now().Add(-at)
+		p.mu.Unlock()
+	}
+
+	seek(stepEnds[0])
+	played := NewWithLocator(p)
+	if connected, err := played.Connect(); !connected || err != nil {
+		t.Fatalf("Connect() to the session = %v, %v, want true, nil", connected, err)
+	}
+	if pid := played.AttachedProcess().PID; pid != FakeProcessID {
+		t.Errorf("PID = %d, want the recorded %d", pid, FakeProcessID)
+	}
+	for i, want := range recorded {
+		seek(stepEnds[i])
+		if err := played.RefreshData(); err != nil {
+			t.Fatalf("RefreshData() at step %d = %v", i, err)
+		}
+		got := played.Snapshot()
+		if got.block != want.block {
+			t.Errorf("block at step %d = %+v, want %+v", i, got.block, want.block)
+		}
+		if !reflect.DeepEqual(got.GetStatsFrame(), want.GetStatsFrame()) {
+			t.Errorf("stats frames at step %d = %+v, want %+v", i, got.GetStatsFrame(), want.GetStatsFrame())
+		}
+	}
+
+	seek(p.Duration())
+	if err := played.RefreshData(); err == nil {
+		t.Error("RefreshData() after the session = nil, want the process to have exited")
+	}
+	if !p.Done() {
+		t.Error("Done() = false after the session")
+	}
+}
+
+func TestSparseMemory(t *testing.T) {
+	type write struct {
+		addr uintptr
+		data string
+	}
+	tests := []struct {
+		name     string
+		writes   []write
+		segments []memorySegment
+	}{
+		{"separate", []write{{10, "ab"}, {20, "cd"}}, []memorySegment{{10, []byte("ab")}, {20, []byte("cd")}}},
+		{"written out of order", []write{{20, "cd"}, {10, "ab"}}, []memorySegment{{10, []byte("ab")}, {20, []byte("cd")}}},
+		{"touching", []write{{10, "ab"}, {12, "cd"}}, []memorySegment{{10, []byte("abcd")}}},
+		{"touching before", []write{{12, "cd"}, {10, "ab"}}, []memorySegment{{10, []byte("abcd")}}},
+		{"overwritten inside", []write{{10, "abcd"}, {11, "XY"}}, []memorySegment{{10, []byte("aXYd")}}},
+		{"overlapping", []write{{10, "abcd"}, {12, "XYZ"}}, []memorySegment{{10, []byte("abXYZ")}}},
+		{"bridging", []write{{10, "ab"}, {15, "cd"}, {11, "XYZW"}}, []memorySegment{{10, []byte("aXYZWcd")}}},
+		{"covering", []write{{11, "a"}, {14, "b"}, {10, "XYZWVU"}}, []memorySegment{{10, []byte("XYZWVU")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m sparseMemory
+			for _, w := range tt.writes {
+				m.write(w.addr, []byte(w.data))
+			}
+			if !reflect.DeepEqual([]memorySegment(m), tt.segments) {
+				t.Errorf("segments = %q, want %q", m, tt.segments)
+			}
+		})
+	}
+
+	var m sparseMemory
+	m.write(10, []byte("abcd"))
+	m.write(20, []byte("ef"))
+	reads := []struct {
+		addr uintptr
+		n    int
+		want string
+		ok   bool
+	}{
+		{10, 4, "abcd", true},
+		{11, 2, "bc", true},
+		{20, 2, "ef", true},
+		{9, 2, "", false},
+		{12, 3, "", false},
+		{13, 8, "", false},
+		{30, 1, "", false},
+	}
+	for _, r := range reads {
+		buf := make([]byte, r.n)
+		if ok := m.read(r.addr, buf); ok != r.ok || (ok && string(buf) != r.want) {
+			t.Errorf("read(%d, %d) = %q, %v, want %q, %v", r.addr, r.n, buf, ok, r.want, r.ok)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package devildaggers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// frameGapThreshold is how long RefreshData can go without being called before
+// the stats frames already read are checked against the game's, in case the run
+// was replaced by another one in the meantime.
+const frameGapThreshold = time.Second
+
+// RunBoundary is why a snapshot was found to start a new run.
+type RunBoundary int
+
+const (
+	// BoundaryNone is when the snapshot continues the run of the previous one.
+	BoundaryNone RunBoundary = iota
+	// BoundaryAttached is when the client attached to a game already in a run.
+	BoundaryAttached
+	// BoundaryStatus is when the status changed to Playing or to a replay, other
+	// than the replay of the run just played.
+	BoundaryStatus
+	// BoundaryRestart is when the timer went back, or the stats frame array was
+	// replaced or shrank, while the status stayed in a run, as when the player
+	// restarts between two reads.
+	BoundaryRestart
+	// BoundaryFrameGap is when reads were missed for a while and the stats frames
+	// read before no longer match the game's.
+	BoundaryFrameGap
+	// BoundaryProcess is when a different game process is in a run, as when the
+	// game was relaunched between two reads.
+	BoundaryProcess
+)
+
+var runBoundaryNames = [...]string{"None", "Attached", "Status", "Restart", "FrameGap", "Process"}
+
+func (b RunBoundary) String() string {
+	if b < 0 || int(b) >= len(runBoundaryNames) {
+		return "Unknown"
+	}
+	return runBoundaryNames[b]
+}
+
+// isRunStatus reports whether a run, played or replayed, is on screen.
+func isRunStatus(status int32) bool {
+	return status == StatusPlaying || status == StatusDead || isReplayStatus(status)
+}
+
+// runBoundary returns why the block n read from the process pid starts a new
+// run after the snapshot prev, or BoundaryNone if it does not. frameGap is set if
+// the stats frames read before n no longer matched the game's.
+func runBoundary(prev *Snapshot, n *DataBlock, pid int, frameGap bool) RunBoundary {
+	if !isRunStatus(n.Status) {
+		return BoundaryNone
+	}
+	if prev == nil {
+		return BoundaryAttached
+	}
+
+	p := &prev.block
+	switch {
+	case pid != 0 && prev.pid != 0 && pid != prev.pid:
+		return BoundaryProcess
+	case n.Status == StatusOwnReplayFromLastRun && (p.Status == StatusDead || p.Status == StatusPlaying):
+		// The replay of the run just played is the same run.
+		return BoundaryNone
+	case n.Status == StatusPlaying && p.Status != StatusPlaying,
+		isReplayStatus(n.Status) && n.Status != p.Status:
+		return BoundaryStatus
+	case restarted(p, n):
+		return BoundaryRestart
+	case frameGap:
+		return BoundaryFrameGap
+	}
+	return BoundaryNone
+}
+
+// checkFrameGap reports whether RefreshData was last called long enough ago for
+// the run to have been replaced without the block showing it, and the stats
+// frame array moved or its first frame differs from the game's. The stats frames
+// are then read again in full.
+func (dd *DevilDaggers) checkFrameGap(now time.Time) bool {
+	gap := !dd.lastRefresh.IsZero() && now.Sub(dd.lastRefresh) > frameGapThreshold
+	dd.lastRefresh = now
+	if !gap || len(dd.statsFrame) == 0 {
+		return false
+	}
+	if dd.dataBlock.StatsBase != dd.statsFrameBase {
+		// refreshStatsFrame reads the moved array afresh.
+		return true
+	}
+
+	dd.framesBuf = growBuffer(dd.framesBuf, statsFrameSize)
+	if err := dd.reader.ReadMemory(uintptr(dd.statsFrameBase), dd.framesBuf); err != nil {
+		return false
+	}
+	dd.record(sessionFrames, uintptr(dd.statsFrameBase), dd.framesBuf)
+
+	var frame StatsFrame
+	decodeStatsFrame(dd.framesBuf, &frame)
+	if frame == dd.statsFrame[0] {
+		return false
+	}
+	// A new slice is used so that frames handed out for the previous run stay intact.
+	dd.statsFrame = nil
+	return true
+}
+
+// newRunID returns a local ID for a run detected at now. IDs sort by the time
+// their run was detected, and the random part keeps runs detected in the same
+// second, or by different clients, apart.
+func newRunID(now time.Time) string {
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%x", now.UTC().Format("20060102T150405Z"), b)
+}
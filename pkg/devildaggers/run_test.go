@@ -0,0 +1,148 @@
+package devildaggers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunBoundary(t *testing.T) {
+	tests := []struct {
+		name     string
+		next     func(b *DataBlock)
+		pid      int
+		frameGap bool
+		want     RunBoundary
+	}{
+		{"same run", func(b *DataBlock) { b.Time += 0.5; b.TimeMax = b.Time }, FakeProcessID, false, BoundaryNone},
+		{"menu", func(b *DataBlock) { b.Status = StatusMenu }, FakeProcessID, false, BoundaryNone},
+		{"restart with time going back", func(b *DataBlock) { b.Time, b.TimeMax = 0.1, 0.1 }, FakeProcessID, false, BoundaryRestart},
+		{"restart with time going forward and a new frame array", func(b *DataBlock) {
+			b.Time, b.TimeMax, b.StatsBase, b.StatsFramesLoaded = 14, 14, FakeStatsFramesAddress+0x1000, 14
+		}, FakeProcessID, false, BoundaryRestart},
+		{"restart with time going forward and fewer frames", func(b *DataBlock) {
+			b.Time, b.TimeMax, b.StatsFramesLoaded = 14, 14, 2
+		}, FakeProcessID, false, BoundaryRestart},
+		{"frame gap", func(b *DataBlock) { b.Time, b.TimeMax, b.StatsFramesLoaded = 20, 20, 20 }, FakeProcessID, true, BoundaryFrameGap},
+		{"other process", func(b *DataBlock) {}, FakeProcessID + 1, false, BoundaryProcess},
+		{"died", func(b *DataBlock) { b.Status = StatusDead; b.IsPlayerAlive = false }, FakeProcessID, false, BoundaryNone},
+		{"replay of the run", func(b *DataBlock) { b.Status, b.Time = StatusOwnReplayFromLastRun, 0 }, FakeProcessID, false, BoundaryNone},
+		{"other replay", func(b *DataBlock) { b.Status = StatusOtherReplay }, FakeProcessID, false, BoundaryStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testBlock()
+			p.StatsBase = FakeStatsFramesAddress
+			prev := newSnapshot(&p, nil, nil, HandStart{})
+			prev.pid = FakeProcessID
+			n := p
+			tt.next(&n)
+			if got := runBoundary(prev, &n, tt.pid, tt.frameGap); got != tt.want {
+				t.Errorf("runBoundary() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	b := testBlock()
+	if got := runBoundary(nil, &b, FakeProcessID, false); got != BoundaryAttached {
+		t.Errorf("runBoundary() of the first block = %s, want Attached", got)
+	}
+}
+
+func TestCheckFrameGap(t *testing.T) {
+	tests := []struct {
+		name    string
+		since   time.Duration
+		replace func(f *FakeProcess, b *DataBlock)
+		want    bool
+	}{
+		{"run replaced between quick reads", 100 * time.Millisecond, func(f *FakeProcess, b *DataBlock) {
+			f.SetStatsFrames(testFrames(3)[1:])
+		}, false},
+		{"same run after a slow tick", 2 * time.Second, func(f *FakeProcess, b *DataBlock) {}, false},
+		{"run replaced after a slow tick", 2 * time.Second, func(f *FakeProcess, b *DataBlock) {
+			f.SetStatsFrames(testFrames(3)[1:])
+		}, true},
+		{"frame array moved after a slow tick", 2 * time.Second, func(f *FakeProcess, b *DataBlock) {
+			b.StatsBase = FakeStatsFramesAddress + 0x1000
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFakeProcess()
+			b := testBlock()
+			f.SetDataBlock(b)
+			f.SetStatsFrames(testFrames(3))
+			dd := connectFake(t, f)
+
+			b.StatsBase = FakeStatsFramesAddress
+			tt.replace(f, &b)
+			*dd.dataBlock = b
+			if got := dd.checkFrameGap(dd.lastRefresh.Add(tt.since)); got != tt.want {
+				t.Errorf("checkFrameGap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartAfterSlowTick(t *testing.T) {
+	f := NewFakeProcess()
+	b := testBlock()
+	f.SetDataBlock(b)
+	f.SetStatsFrames(testFrames(3))
+	dd := connectFake(t, f)
+	first := dd.Snapshot().GetRunID()
+
+	// The new run is already further in than the old one was.
+	b.Time, b.TimeMax, b.StatsFramesLoaded = 20, 20, 1
+	f.SetDataBlock(b)
+	f.SetStatsFrames(testFrames(1))
+	if err := dd.RefreshData(); err != nil {
+		t.Fatalf("RefreshData() = %v", err)
+	}
+	s := dd.Snapshot()
+	if s.GetRunBoundary() != BoundaryRestart || s.GetRunID() == first {
+		t.Errorf("run %s with boundary %s after a restart, want a new run with boundary Restart", s.GetRunID(), s.GetRunBoundary())
+	}
+}
+
+func TestReplayOfLastRunKeepsRun(t *testing.T) {
+	f := NewFakeProcess()
+	b := testBlock()
+	f.SetDataBlock(b)
+	f.SetStatsFrames(testFrames(3))
+	dd := NewWithLocator(f)
+	dd.SetTimelineInterval(0)
+	if connected, err := dd.Connect(); !connected || err != nil {
+		t.Fatalf("Connect() = %v, %v, want true, nil", connected, err)
+	}
+
+	steps := []func(b *DataBlock){
+		func(b *DataBlock) {},
+		func(b *DataBlock) { b.Time, b.TimeMax = 13, 13 },
+		func(b *DataBlock) { b.Status, b.IsPlayerAlive = StatusDead, false },
+		func(b *DataBlock) { b.Status, b.Time = StatusOwnReplayFromLastRun, 0.2 },
+		func(b *DataBlock) { b.Time = 5 },
+	}
+	var runID string
+	var timeline []TimelineSample
+	for i, step := range steps {
+		step(&b)
+		f.SetDataBlock(b)
+		if err := dd.RefreshData(); err != nil {
+			t.Fatalf("RefreshData() at step %d = %v", i, err)
+		}
+		s := dd.Snapshot()
+		if i == 0 {
+			runID = s.GetRunID()
+		}
+		if i == 2 {
+			timeline = s.GetTimeline()
+		}
+		if s.GetRunID() != runID {
+			t.Fatalf("run %s at step %d, want the run %s carried into its replay", s.GetRunID(), i, runID)
+		}
+	}
+	if got := dd.Snapshot().GetTimeline(); len(got) != len(timeline) || len(got) != 2 {
+		t.Errorf("timeline of %d samples during the replay, want the run's %d", len(got), len(timeline))
+	}
+}
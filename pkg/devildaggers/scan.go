@@ -0,0 +1,86 @@
+package devildaggers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ddstatsHeader is the marker that precedes the data block in the game's memory.
+const ddstatsHeader = "__ddstats__\x00"
+
+const (
+	// scanChunkSize is how much memory is read at a time while scanning a region.
+	scanChunkSize = 1 << 20
+	// maxScanRegionSize skips regions too large to plausibly hold the block,
+	// such as reserved graphics memory.
+	maxScanRegionSize = 256 << 20
+	// maxPlausibleDDStatsVersion bounds the version field that follows the
+	// marker, so stray copies of the "__ddstats__" string are not mistaken for the block.
+	maxPlausibleDDStatsVersion = 100
+)
+
+// ErrBlockNotFound is returned when the game is running but its memory holds no
+// __ddstats__ block, usually because it is not a ddstats-enabled build.
+var ErrBlockNotFound = errors.New("__ddstats__ block not found")
+
+// validateBlockHeader checks that addr points at the "__ddstats__" marker followed
+// by a plausible block version.
+func validateBlockHeader(r MemoryReader, addr address) error {
+	buf := make([]byte, len(ddstatsHeader)+4)
+	err := r.ReadMemory(uintptr(addr), buf)
+	if err != nil {
+		return fmt.Errorf("validateBlockHeader: unable to read process memory: %w", err)
+	}
+
+	if !bytes.Equal(buf[:len(ddstatsHeader)], []byte(ddstatsHeader)) {
+		return fmt.Errorf("validateBlockHeader: no __ddstats__ marker at 0x%x", addr)
+	}
+
+	version := int32(binary.LittleEndian.Uint32(buf[len(ddstatsHeader):]))
+	if version <= 0 || version > maxPlausibleDDStatsVersion {
+		return fmt.Errorf("validateBlockHeader: implausible ddstats version %d at 0x%x", version, addr)
+	}
+
+	return nil
+}
+
+// scanForBlock searches the given regions for the "__ddstats__" marker and returns
+// the address of the first one with a valid header.
+func scanForBlock(r MemoryReader, regions []MemoryRegion) (address, error) {
+	marker := []byte(ddstatsHeader)
+	// Chunks overlap by the length of the marker so that a marker straddling
+	// two chunks is still found.
+	buf := make([]byte, scanChunkSize+len(marker))
+
+	for _, region := range regions {
+		if region.Size > maxScanRegionSize {
+			continue
+		}
+		for offset := uintptr(0); offset < region.Size; offset += scanChunkSize {
+			n := region.Size - offset
+			if n > uintptr(len(buf)) {
+				n = uintptr(len(buf))
+			}
+			chunk := buf[:n]
+			if err := r.ReadMemory(region.Address+offset, chunk); err != nil {
+				// Regions can be unmapped or protected between listing and reading.
+				break
+			}
+			for i := 0; ; {
+				j := bytes.Index(chunk[i:], marker)
+				if j < 0 {
+					break
+				}
+				candidate := address(region.Address + offset + uintptr(i+j))
+				if validateBlockHeader(r, candidate) == nil {
+					return candidate, nil
+				}
+				i += j + 1
+			}
+		}
+	}
+
+	return 0, ErrBlockNotFound
+}
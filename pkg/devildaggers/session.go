@@ -0,0 +1,207 @@
+package devildaggers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// sessionMagic starts every session file, followed by the format version.
+const (
+	sessionMagic         = "ddstats session\x00"
+	sessionFormatVersion = 1
+	// maxSessionRecordSize guards against corrupt sizes; frame reads of even very
+	// long runs are far smaller.
+	maxSessionRecordSize = 64 << 20
+)
+
+// Kinds of session records.
+const (
+	// sessionAttach is when a process was attached to. The address is the block
+	// data address, and the data holds the module base address and the PID.
+	sessionAttach byte = iota + 1
+	// sessionDetach is when the process was closed.
+	sessionDetach
+	// sessionBlock is a read of the __ddstats__ block data.
+	sessionBlock
+	// sessionFrames is a read of stats frames.
+	sessionFrames
+)
+
+// sessionRecord is one record of a session file. at is the time since the
+// start of the session.
+type sessionRecord struct {
+	kind byte
+	at   time.Duration
+	addr uintptr
+	data []byte
+}
+
+// SessionRecorder writes the raw memory read from the game to a compact,
+// gzip-compressed session file, which a SessionPlayer can serve back as if it
+// were the live process. Recording errors never interrupt reading the game;
+// they are reported by Err.
+type SessionRecorder struct {
+	mu        sync.Mutex
+	gz        *gzip.Writer
+	w         *bufio.Writer
+	start     time.Time
+	last      time.Duration
+	lastBlock []byte
+	varint    [binary.MaxVarintLen64]byte
+	err       error
+}
+
+// NewSessionRecorder starts a session file written to w. Close must be called to
+// flush it; w itself is not closed.
+func NewSessionRecorder(w io.Writer) (*SessionRecorder, error) {
+	gz := gzip.NewWriter(w)
+	r := &SessionRecorder{gz: gz, w: bufio.NewWriter(gz), start: time.Now()}
+	r.w.WriteString(sessionMagic)
+	r.w.WriteByte(sessionFormatVersion)
+	if err := r.w.Flush(); err != nil {
+		return nil, fmt.Errorf("NewSessionRecorder: could not write header: %w", err)
+	}
+	return r, nil
+}
+
+// Err returns the first error that happened while recording.
+func (r *SessionRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close flushes the session file.
+func (r *SessionRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.gz.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		return fmt.Errorf("Close: could not write session: %w", r.err)
+	}
+	return nil
+}
+
+// record appends a record. Block reads identical to the previous one are skipped,
+// which keeps idle periods in the menus from growing the file.
+func (r *SessionRecorder) record(kind byte, addr uintptr, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	switch kind {
+	case sessionBlock:
+		if bytes.Equal(r.lastBlock, data) {
+			return
+		}
+		r.lastBlock = append(r.lastBlock[:0], data...)
+	case sessionAttach, sessionDetach:
+		r.lastBlock = r.lastBlock[:0]
+	}
+
+	at := time.Since(r.start)
+	r.w.WriteByte(kind)
+	r.writeUvarint(uint64(at - r.last))
+	r.writeUvarint(uint64(addr))
+	r.writeUvarint(uint64(len(data)))
+	_, err := r.w.Write(data)
+	if err != nil {
+		r.err = err
+	}
+	r.last = at
+}
+
+func (r *SessionRecorder) writeUvarint(v uint64) {
+	n := binary.PutUvarint(r.varint[:], v)
+	r.w.Write(r.varint[:n])
+}
+
+// SetSessionRecorder records the memory read from the game to r from the next
+// attach on. A nil recorder stops recording.
+func (dd *DevilDaggers) SetSessionRecorder(r *SessionRecorder) {
+	dd.recorder.Store(r)
+}
+
+// record sends a read to the session recorder, if one is set.
+func (dd *DevilDaggers) record(kind byte, addr uintptr, data []byte) {
+	if r, _ := dd.recorder.Load().(*SessionRecorder); r != nil {
+		r.record(kind, addr, data)
+	}
+}
+
+// recordAttach records the process just attached to.
+func (dd *DevilDaggers) recordAttach() {
+	if r, _ := dd.recorder.Load().(*SessionRecorder); r != nil {
+		var data [12]byte
+		binary.LittleEndian.PutUint64(data[0:], uint64(dd.reader.BaseAddress()))
+		binary.LittleEndian.PutUint32(data[8:], uint32(dd.AttachedProcess().PID))
+		r.record(sessionAttach, uintptr(dd.ddstatsBlockAddress), data[:])
+	}
+}
+
+// readSession reads every record of a session file.
+func readSession(rd io.Reader) ([]sessionRecord, error) {
+	gz, err := gzip.NewReader(rd)
+	if err != nil {
+		return nil, fmt.Errorf("readSession: not a session file: %w", err)
+	}
+	defer gz.Close()
+	br := bufio.NewReader(gz)
+
+	header := make([]byte, len(sessionMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(sessionMagic)]) != sessionMagic {
+		return nil, errors.New("readSession: not a session file")
+	}
+	if header[len(sessionMagic)] != sessionFormatVersion {
+		return nil, fmt.Errorf("readSession: unsupported session format version %d", header[len(sessionMagic)])
+	}
+
+	var records []sessionRecord
+	var at time.Duration
+	for {
+		kind, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("readSession: could not read record: %w", err)
+		}
+		delta, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("readSession: could not read record time: %w", err)
+		}
+		addr, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("readSession: could not read record address: %w", err)
+		}
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("readSession: could not read record size: %w", err)
+		}
+		if size > maxSessionRecordSize {
+			return nil, fmt.Errorf("readSession: record of %d bytes is too large", size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("readSession: truncated record: %w", err)
+		}
+		at += time.Duration(delta)
+		records = append(records, sessionRecord{kind: kind, at: at, addr: uintptr(addr), data: data})
+	}
+
+	return records, nil
+}
@@ -0,0 +1,260 @@
+package devildaggers
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot is an immutable copy of the __ddstats__ block and the stats frames
+// read on one tick, along with the values derived from them. RefreshData
+// publishes a new Snapshot whenever the game's data changes, so a consumer that
+// reads everything it needs from one Snapshot sees one consistent tick.
+type Snapshot struct {
+	block              DataBlock
+	frames             []StatsFrame
+	timeline           []TimelineSample
+	takenAt            time.Time
+	unsupportedVersion int32
+	handStart          HandStart
+	pid                int
+	runID              string
+	boundary           RunBoundary
+
+	time                float32
+	gemsCollected       int32
+	totalGems           int32
+	accuracy            float32
+	homingMaxTime       float32
+	enemiesAliveMaxTime float32
+	timeMax             float32
+}
+
+func newSnapshot(block *DataBlock, frames []StatsFrame, timeline []TimelineSample, handStart HandStart) *Snapshot {
+	s := &Snapshot{
+		block:     *block,
+		frames:    frames,
+		timeline:  timeline,
+		takenAt:   time.Now(),
+		handStart: handStart,
+	}
+	s.time = block.StartingTime + block.Time
+	s.gemsCollected = handStart.Gems() + block.GemsCollected
+	s.totalGems = handStart.Gems() + block.TotalGems
+	if block.DaggersFired != 0 {
+		s.accuracy = float32(block.DaggersHit) / float32(block.DaggersFired) * 100
+	}
+	s.homingMaxTime = block.StartingTime + block.TimeHomingMax
+	s.enemiesAliveMaxTime = block.StartingTime + block.TimeEnemiesAliveMax
+	s.timeMax = block.StartingTime + block.TimeMax
+	return s
+}
+
+// GetTakenAt returns the wall-clock time the snapshot was taken.
+func (s *Snapshot) GetTakenAt() time.Time {
+	return s.takenAt
+}
+
+// GetRunID returns the local ID of the run on screen, or of the last run if
+// none is. It is empty until a run has been seen.
+func (s *Snapshot) GetRunID() string {
+	return s.runID
+}
+
+// GetRunBoundary returns why the snapshot starts a new run, or BoundaryNone if
+// it continues the run of the previous snapshot.
+func (s *Snapshot) GetRunBoundary() RunBoundary {
+	return s.boundary
+}
+
+// GetUnsupportedVersion returns the block version of the attached game if this
+// client has no layout for it, or 0 if the version is supported. The other
+// values of a snapshot with an unsupported version are all zero.
+func (s *Snapshot) GetUnsupportedVersion() int32 {
+	return s.unsupportedVersion
+}
+
+func (s *Snapshot) GetDDStatsVersion() int32 {
+	return s.block.DDStatsVersion
+}
+
+func (s *Snapshot) GetPlayerID() int32 {
+	return s.block.PlayerID
+}
+
+func (s *Snapshot) GetPlayerName() string {
+	return byteArrayToString(&s.block.UserName)
+}
+
+func (s *Snapshot) GetTime() float32 {
+	return s.time
+}
+
+func (s *Snapshot) GetGemsCollected() int32 {
+	return s.gemsCollected
+}
+
+func (s *Snapshot) GetKills() int32 {
+	return s.block.Kills
+}
+
+func (s *Snapshot) GetDaggersFired() int32 {
+	return s.block.DaggersFired
+}
+
+func (s *Snapshot) GetDaggersHit() int32 {
+	return s.block.DaggersHit
+}
+
+func (s *Snapshot) GetAccuracy() float32 {
+	return s.accuracy
+}
+
+func (s *Snapshot) GetEnemiesAlive() int32 {
+	return s.block.EnemiesAlive
+}
+
+func (s *Snapshot) GetLevelGems() int32 {
+	return s.block.LevelGems
+}
+
+func (s *Snapshot) GetHomingDaggers() int32 {
+	return s.block.HomingDaggers
+}
+
+func (s *Snapshot) GetGemsDespawned() int32 {
+	return s.block.GemsDespawned
+}
+
+func (s *Snapshot) GetGemsEaten() int32 {
+	return s.block.GemsEaten
+}
+
+func (s *Snapshot) GetTotalGems() int32 {
+	return s.totalGems
+}
+
+func (s *Snapshot) GetDaggersEaten() int32 {
+	return s.block.DaggersEaten
+}
+
+func (s *Snapshot) GetIsPlayerAlive() bool {
+	return s.block.IsPlayerAlive
+}
+
+func (s *Snapshot) GetIsReplay() bool {
+	return s.block.IsReplay
+}
+
+func (s *Snapshot) GetDeathType() DeathType {
+	return DeathType(s.block.DeathType)
+}
+
+func (s *Snapshot) GetIsInGame() bool {
+	return s.block.IsInGame
+}
+
+func (s *Snapshot) GetReplayPlayerID() int32 {
+	return s.block.ReplayPlayerID
+}
+
+func (s *Snapshot) GetReplayPlayerName() string {
+	return byteArrayToString(&s.block.ReplayPlayerName)
+}
+
+func (s *Snapshot) GetLevelHashMD5() string {
+	return fmt.Sprintf("%x", s.block.LevelHashMD5)
+}
+
+func (s *Snapshot) GetTimeLvl2() float32 {
+	return s.block.TimeLvl2
+}
+
+func (s *Snapshot) GetTimeLvl3() float32 {
+	return s.block.TimeLvl3
+}
+
+func (s *Snapshot) GetTimeLvl4() float32 {
+	return s.block.TimeLvl4
+}
+
+func (s *Snapshot) GetLeviathanDownTime() float32 {
+	return s.block.LeviDownTime
+}
+
+func (s *Snapshot) GetOrbDownTime() float32 {
+	return s.block.OrbDownTime
+}
+
+func (s *Snapshot) GetStatus() int32 {
+	return s.block.Status
+}
+
+func (s *Snapshot) GetHomingMax() int32 {
+	return s.block.HomingMax
+}
+
+func (s *Snapshot) GetHomingMaxTime() float32 {
+	return s.homingMaxTime
+}
+
+func (s *Snapshot) GetEnemiesAliveMax() int32 {
+	return s.block.EnemiesAliveMax
+}
+
+func (s *Snapshot) GetEnemiesAliveMaxTime() float32 {
+	return s.enemiesAliveMaxTime
+}
+
+func (s *Snapshot) GetTimeMax() float32 {
+	return s.timeMax
+}
+
+func (s *Snapshot) GetStatsFramesLoaded() int32 {
+	return s.block.StatsFramesLoaded
+}
+
+func (s *Snapshot) GetStatsFinishedLoading() bool {
+	return s.block.StatsFinishedLoading
+}
+
+func (s *Snapshot) GetStartingHandLevel() int32 {
+	return s.block.StartingHandLevel
+}
+
+func (s *Snapshot) GetStartingHomingCount() int32 {
+	return s.block.StartingHomingCount
+}
+
+func (s *Snapshot) GetStartingTime() float32 {
+	return s.block.StartingTime
+}
+
+func (s *Snapshot) GetProhibitedMods() bool {
+	return s.block.ProhibitedMods
+}
+
+// GetStartingGemOffset returns the gems the run started with, which the game's
+// gem counts do not include. See GetHandStart.
+func (s *Snapshot) GetStartingGemOffset() int32 {
+	return s.handStart.Gems()
+}
+
+// GetStatsFrame returns the stats frames read up to the time the snapshot was taken.
+func (s *Snapshot) GetStatsFrame() []StatsFrame {
+	return s.frames
+}
+
+// GetStatsFramesComplete reports whether every frame the game had loaded was read
+// into the snapshot.
+func (s *Snapshot) GetStatsFramesComplete() bool {
+	return len(s.frames) >= int(s.block.StatsFramesLoaded)
+}
+
+func byteArrayToString(a *[32]byte) string {
+	for i, b := range a {
+		if b == 0 {
+			return string(a[:i])
+		}
+	}
+	return string(a[:])
+}
@@ -0,0 +1,108 @@
+package devildaggers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSnapshotConcurrentReaders plays a run on a FakeProcess read by the
+// persistent connection while other goroutines read snapshots and receive
+// events, as the UI and socket.io goroutines of the client do. Run it with
+// -race to check that snapshots are handed over safely.
+func TestSnapshotConcurrentReaders(t *testing.T) {
+	const ticks = 40
+
+	f := NewFakeProcess()
+	dd := NewWithLocator(f)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s := dd.Snapshot()
+				if s == nil {
+					continue
+				}
+				// Every block of the run has Time equal to TimeMax and one frame
+				// per second, so a snapshot mixing two ticks shows up here.
+				if s.GetTime() != s.GetTimeMax() {
+					t.Errorf("snapshot has time %v and max time %v", s.GetTime(), s.GetTimeMax())
+					return
+				}
+				if frames := s.GetStatsFrame(); len(frames) > int(s.GetStatsFramesLoaded()) {
+					t.Errorf("snapshot has %d frames of %d loaded", len(frames), s.GetStatsFramesLoaded())
+					return
+				}
+				_ = s.GetPlayerName()
+				_ = s.GetEnemiesAliveByType()
+			}
+		}()
+	}
+
+	died := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		events, unsubscribe := dd.Subscribe()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer unsubscribe()
+			for {
+				select {
+				case <-done:
+					return
+				case e := <-events:
+					if e.Snapshot != nil {
+						_ = e.Snapshot.GetStatsFrame()
+						_ = e.Snapshot.GetRunID()
+					}
+					if e.Type == EventDied && i == 0 {
+						close(died)
+					}
+				}
+			}
+		}(i)
+	}
+
+	dd.StartPersistentConnection(ctx)
+
+	for tick := 1; tick <= ticks; tick++ {
+		b := testBlock()
+		b.Time = float32(tick) / 4
+		b.TimeMax = b.Time
+		b.TimeLvl2 = 0
+		b.StatsFramesLoaded = int32(tick / 4)
+		if tick == ticks {
+			b.IsPlayerAlive = false
+			b.Status = StatusDead
+		}
+		f.SetStatsFrames(testFrames(tick / 4))
+		f.SetDataBlock(b)
+		time.Sleep(2 * persistentConnectionTickRate)
+	}
+
+	select {
+	case <-died:
+	case <-time.After(5 * time.Second):
+		t.Error("no EventDied received")
+	}
+	close(done)
+	dd.StopPersistentConnection()
+	wg.Wait()
+
+	if s := dd.Snapshot(); s != nil {
+		t.Errorf("Snapshot() = %p after the connection stopped, want nil", s)
+	}
+}
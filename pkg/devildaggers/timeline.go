@@ -0,0 +1,67 @@
+package devildaggers
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TimelineSample is the state of a run at one tick. Unlike a StatsFrame, which
+// the game records once a second, samples are taken as often as the block is
+// read, so sub-second moments such as homing dumps and gem pickups show up. The
+// time and gems include the starting time and gems, like the Snapshot getters.
+type TimelineSample struct {
+	Time               float32                `json:"time"`
+	GemsCollected      int32                  `json:"gems"`
+	HomingDaggers      int32                  `json:"homing"`
+	EnemiesAlive       int32                  `json:"enemies_alive"`
+	PerEnemyAliveCount [enemyCountSlots]int16 `json:"per_enemy_alive"`
+	PerEnemyKillCount  [enemyCountSlots]int16 `json:"per_enemy_killed"`
+}
+
+// SetTimelineInterval turns on recording a timeline of the current run, taking a
+// sample whenever at least interval of game time has passed since the last one.
+// An interval of zero samples every tick the game time changes on, and a
+// negative interval turns the timeline off, which is the default.
+func (dd *DevilDaggers) SetTimelineInterval(interval time.Duration) {
+	atomic.StoreInt64(&dd.timelineInterval, int64(interval))
+}
+
+// recordTimeline adds a sample of the current block to the run's timeline. A new
+// timeline is started when a new run starts. The replay of the run just played
+// is not sampled: it continues that run, whose timeline was taken as it was played.
+func (dd *DevilDaggers) recordTimeline() {
+	b := dd.dataBlock
+	interval := time.Duration(atomic.LoadInt64(&dd.timelineInterval))
+	if interval < 0 || !b.IsInGame || b.Status == StatusOwnReplayFromLastRun {
+		return
+	}
+
+	gameTime := b.StartingTime + b.Time
+	n := len(dd.timeline)
+	if n > 0 {
+		last := &dd.timeline[n-1]
+		if b.StatsBase != dd.timelineBase || gameTime < last.Time {
+			// A new slice is used so that the timeline handed out for the previous run stays intact.
+			dd.timeline = nil
+		} else if gameTime == last.Time || time.Duration(float64(gameTime-last.Time)*float64(time.Second)) < interval {
+			return
+		}
+	}
+	dd.timelineBase = b.StatsBase
+
+	dd.timeline = append(dd.timeline, TimelineSample{
+		Time:               gameTime,
+		GemsCollected:      dd.resolveHandStart().Gems() + b.GemsCollected,
+		HomingDaggers:      b.HomingDaggers,
+		EnemiesAlive:       b.EnemiesAlive,
+		PerEnemyAliveCount: b.PerEnemyAliveCount,
+		PerEnemyKillCount:  b.PerEnemyKillCount,
+	})
+}
+
+// GetTimeline returns the timeline of the run up to the time the snapshot was
+// taken, or nil if timelines are turned off. After the run ends, snapshots keep
+// returning the run's full timeline until the next run starts.
+func (s *Snapshot) GetTimeline() []TimelineSample {
+	return s.timeline
+}
@@ -0,0 +1,183 @@
+package devildaggers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// maxStatsFrames bounds StatsFramesLoaded regardless of the timers, so a
+	// corrupt count can never cause a huge read. It is over 24 hours of frames.
+	maxStatsFrames = 1 << 17
+	// statsFrameSlack is how many more stats frames than elapsed seconds are
+	// accepted, as the game records one frame per second plus the final one.
+	statsFrameSlack = 5
+	// restartTimeThreshold is the highest time a run that was just restarted can
+	// be read at. A timer going backwards to above it is not a restart.
+	restartTimeThreshold = 0.5
+	// corruptReadRetries is how many times a block failing validation is read
+	// again straight away before it is quarantined.
+	corruptReadRetries = 1
+	// quarantineLimit is how many reads in a row can be rejected for going
+	// backwards from the last good block before the new state is trusted, so a
+	// missed restart cannot freeze the client. Checks of the block on its own are
+	// never waived.
+	quarantineLimit = 30
+)
+
+// CorruptReadError is returned when memory read from the game fails validation,
+// for example because the read was torn by the game writing to it or went
+// through a stale pointer after a restart. The data is not published, so it is
+// never shown or submitted, and it is read again on the next refresh.
+type CorruptReadError struct {
+	Problems []string
+}
+
+func (e *CorruptReadError) Error() string {
+	return "corrupt read from game memory: " + strings.Join(e.Problems, "; ")
+}
+
+// validateBlock returns the problems found in a decoded block, or nil if it is
+// plausible. prev is the last block that passed validation in the same process,
+// or nil, and is used to check that timers only move forward and the spawnset
+// stays the same within a run. start is the hand start the spawnset registry
+// gives b's spawnset, or nil if it is unknown, and is checked against the level
+// the game reports the run started at. It does not allocate unless there are
+// problems.
+func validateBlock(b, prev *DataBlock, start *HandStart) []string {
+	var problems []string
+
+	if b.Status < StatusTitle || b.Status > StatusOtherReplay {
+		problems = append(problems, fmt.Sprintf("unknown status %d", b.Status))
+	}
+
+	counts := [...]struct {
+		name  string
+		value int32
+	}{
+		{"GemsCollected", b.GemsCollected}, {"Kills", b.Kills}, {"DaggersFired", b.DaggersFired},
+		{"DaggersHit", b.DaggersHit}, {"EnemiesAlive", b.EnemiesAlive}, {"LevelGems", b.LevelGems},
+		{"HomingDaggers", b.HomingDaggers}, {"GemsDespawned", b.GemsDespawned}, {"GemsEaten", b.GemsEaten},
+		{"TotalGems", b.TotalGems}, {"DaggersEaten", b.DaggersEaten}, {"HomingMax", b.HomingMax},
+		{"EnemiesAliveMax", b.EnemiesAliveMax}, {"StatsFramesLoaded", b.StatsFramesLoaded},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			problems = append(problems, fmt.Sprintf("negative %s %d", c.name, c.value))
+		}
+	}
+	for i := range b.PerEnemyAliveCount {
+		if b.PerEnemyAliveCount[i] < 0 || b.PerEnemyKillCount[i] < 0 {
+			problems = append(problems, fmt.Sprintf("negative count for %s", EnemyType(i)))
+		}
+	}
+
+	timers := [...]struct {
+		name  string
+		value float32
+	}{
+		{"Time", b.Time}, {"TimeMax", b.TimeMax}, {"TimeLvl2", b.TimeLvl2}, {"TimeLvl3", b.TimeLvl3},
+		{"TimeLvl4", b.TimeLvl4}, {"LeviDownTime", b.LeviDownTime}, {"OrbDownTime", b.OrbDownTime},
+		{"TimeHomingMax", b.TimeHomingMax}, {"TimeEnemiesAliveMax", b.TimeEnemiesAliveMax},
+	}
+	for _, t := range timers {
+		if t.value < 0 || math.IsNaN(float64(t.value)) || math.IsInf(float64(t.value), 0) {
+			problems = append(problems, fmt.Sprintf("invalid %s %v", t.name, t.value))
+		}
+	}
+	if (b.TimeLvl3 != 0 && b.TimeLvl3 < b.TimeLvl2) || (b.TimeLvl4 != 0 && b.TimeLvl4 < b.TimeLvl3) {
+		problems = append(problems, "hand levels reached out of order")
+	}
+
+	elapsed := b.Time
+	if b.TimeMax > elapsed {
+		elapsed = b.TimeMax
+	}
+	if b.StatsFramesLoaded > maxStatsFrames ||
+		(elapsed >= 0 && float64(b.StatsFramesLoaded) > float64(elapsed)+statsFrameSlack) {
+		problems = append(problems, fmt.Sprintf("%d stats frames for %.1f seconds", b.StatsFramesLoaded, elapsed))
+	}
+	if b.StatsFramesLoaded > 0 && b.StatsBase <= 0 {
+		problems = append(problems, fmt.Sprintf("stats frames loaded from invalid address 0x%x", b.StatsBase))
+	}
+
+	if b.IsInGame && b.LevelHashMD5 == [16]byte{} {
+		problems = append(problems, "in game without a spawnset hash")
+	}
+	if level := HandLevel(b.StartingHandLevel); b.IsInGame && start != nil && level.Valid() && level != start.Level {
+		problems = append(problems, fmt.Sprintf("spawnset %x starts at %s, not at %s", b.LevelHashMD5, start.Level, level))
+	}
+	if prev != nil && spawnsetChanged(prev, b) {
+		problems = append(problems, fmt.Sprintf("spawnset hash changed from %x to %x within a run", prev.LevelHashMD5, b.LevelHashMD5))
+	}
+
+	if prev != nil && sameRun(prev, b) && b.Time < prev.Time && !restarted(prev, b) {
+		problems = append(problems, fmt.Sprintf("timer went back from %.4f to %.4f", prev.Time, b.Time))
+	}
+
+	return problems
+}
+
+// sameRun reports whether two blocks are read from the same ongoing run, where
+// the timer can only move forward.
+func sameRun(prev, next *DataBlock) bool {
+	return prev.Status == next.Status && prev.StatsBase == next.StatsBase &&
+		prev.LevelHashMD5 == next.LevelHashMD5 && (next.Status == StatusPlaying || isReplayStatus(next.Status))
+}
+
+// spawnsetChanged reports whether the spawnset hash changed between two blocks
+// read from the same ongoing run, which the game never does: the stats frame
+// array is the same and the timer kept going.
+func spawnsetChanged(prev, next *DataBlock) bool {
+	return prev.LevelHashMD5 != next.LevelHashMD5 && prev.LevelHashMD5 != [16]byte{} &&
+		prev.Status == next.Status && (next.Status == StatusPlaying || isReplayStatus(next.Status)) &&
+		prev.StatsBase == next.StatsBase && next.Time >= prev.Time
+}
+
+// restarted reports whether next was read after the run of prev was restarted.
+// The stats frame array being replaced or shrinking is a restart on its own, as
+// the game only does either for a new run, even one whose timer already passed
+// the old one's by the time it was read. Otherwise the timer went back either to
+// about zero or along with TimeMax, which only grows within a run. A torn read
+// seldom tears two values the same way.
+func restarted(prev, next *DataBlock) bool {
+	if (prev.StatsBase != 0 && next.StatsBase != prev.StatsBase) || next.StatsFramesLoaded < prev.StatsFramesLoaded {
+		return true
+	}
+	if next.Time >= prev.Time {
+		return false
+	}
+	return next.Time <= restartTimeThreshold || next.TimeMax < prev.TimeMax
+}
+
+// validateStatsFrame returns the problems found in a decoded stats frame, or nil.
+func validateStatsFrame(frame *StatsFrame) []string {
+	var problems []string
+	values := [...]int32{frame.GemsCollected, frame.Kills, frame.DaggersFired, frame.DaggersHit,
+		frame.EnemiesAlive, frame.LevelGems, frame.HomingDaggers, frame.GemsDespawned, frame.GemsEaten,
+		frame.TotalGems, frame.DaggersEaten}
+	for _, v := range values {
+		if v < 0 {
+			problems = append(problems, "negative count in stats frame")
+			break
+		}
+	}
+	for i := range frame.PerEnemyAliveCount {
+		if frame.PerEnemyAliveCount[i] < 0 || frame.PerEnemyKillCount[i] < 0 {
+			problems = append(problems, "negative enemy count in stats frame")
+			break
+		}
+	}
+	return problems
+}
+
+// reportCorruptRead tells subscribers about a quarantined read and returns it as
+// a *CorruptReadError.
+func (dd *DevilDaggers) reportCorruptRead(problems []string) error {
+	err := &CorruptReadError{Problems: problems}
+	if dd.events.hasSubscribers() {
+		dd.events.publish([]Event{{Type: EventCorruptRead, Err: err}})
+	}
+	return err
+}
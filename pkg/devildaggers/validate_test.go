@@ -0,0 +1,85 @@
+package devildaggers
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidateBlock(t *testing.T) {
+	otherHash := testLevelHash
+	otherHash[0]++
+	level1 := &HandStart{Level: HandLevel1}
+	level3 := &HandStart{Level: HandLevel3}
+
+	tests := []struct {
+		name  string
+		prev  func(b *DataBlock)
+		next  func(b *DataBlock)
+		start *HandStart
+		want  string
+	}{
+		{"valid", nil, func(b *DataBlock) {}, nil, ""},
+		{"valid after the previous block", func(b *DataBlock) {}, func(b *DataBlock) { b.Time = 13 }, level1, ""},
+		{"bad status", nil, func(b *DataBlock) { b.Status = 42 }, nil, "unknown status 42"},
+		{"negative count", nil, func(b *DataBlock) { b.Kills = -1 }, nil, "negative Kills -1"},
+		{"negative enemy count", nil, func(b *DataBlock) { b.PerEnemyKillCount[EnemySquid2] = -3 }, nil, "negative count for"},
+		{"NaN timer", nil, func(b *DataBlock) { b.TimeLvl2 = float32(math.NaN()) }, nil, "invalid TimeLvl2"},
+		{"hand levels out of order", nil, func(b *DataBlock) { b.TimeLvl3 = 5 }, nil, "hand levels reached out of order"},
+		{"too many frames for TimeMax", nil, func(b *DataBlock) { b.StatsFramesLoaded = 40 }, nil, "40 stats frames for 12.5 seconds"},
+		{"frames without an array", nil, func(b *DataBlock) { b.StatsBase = 0 }, nil, "invalid address"},
+		{"in game without a hash", nil, func(b *DataBlock) { b.LevelHashMD5 = [16]byte{} }, nil, "without a spawnset hash"},
+		{"timer going backwards", func(b *DataBlock) {}, func(b *DataBlock) { b.Time = 7 }, nil, "timer went back from 12.5000 to 7.0000"},
+		{"restart", func(b *DataBlock) {}, func(b *DataBlock) { b.Time, b.StatsFramesLoaded = 0.1, 0 }, nil, ""},
+		{"hash changed within the run", func(b *DataBlock) {}, func(b *DataBlock) { b.Time, b.LevelHashMD5 = 13, otherHash }, nil, "spawnset hash changed"},
+		{"hash changed with a new run", func(b *DataBlock) {}, func(b *DataBlock) {
+			b.Time, b.LevelHashMD5, b.StatsBase = 0.1, otherHash, FakeStatsFramesAddress+0x1000
+		}, nil, ""},
+		{"hash changed after dying", func(b *DataBlock) { b.Status = StatusDead }, func(b *DataBlock) { b.LevelHashMD5 = otherHash }, nil, ""},
+		{"hash not fitting the starting level", nil, func(b *DataBlock) {}, level3, "starts at Level 3, not at Level 1"},
+		{"unknown starting level", nil, func(b *DataBlock) { b.StartingHandLevel = 0 }, level3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prev *DataBlock
+			if tt.prev != nil {
+				p := testBlock()
+				p.StatsBase = FakeStatsFramesAddress
+				tt.prev(&p)
+				prev = &p
+			}
+			b := testBlock()
+			b.StatsBase = FakeStatsFramesAddress
+			tt.next(&b)
+
+			problems := validateBlock(&b, prev, tt.start)
+			got := strings.Join(problems, "; ")
+			if tt.want == "" && problems != nil {
+				t.Errorf("validateBlock() = %q, want no problems", got)
+			}
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("validateBlock() = %q, want a problem containing %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshDataChecksSpawnset(t *testing.T) {
+	f := NewFakeProcess()
+	b := testBlock()
+	f.SetDataBlock(b)
+	f.SetStatsFrames(testFrames(3))
+	dd := connectFake(t, f)
+	dd.SetHandStartResolver(func(levelHash [16]byte) (HandStart, bool) {
+		return HandStart{Level: HandLevel2}, levelHash == testLevelHash
+	})
+
+	if err := dd.RefreshData(); err == nil || !strings.Contains(err.Error(), "starts at Level 2") {
+		t.Errorf("RefreshData() = %v, want a corrupt read for the starting level", err)
+	}
+	b.StartingHandLevel = int32(HandLevel2)
+	f.SetDataBlock(b)
+	if err := dd.RefreshData(); err != nil {
+		t.Errorf("RefreshData() = %v once the starting level fits", err)
+	}
+}
@@ -7,8 +7,13 @@ import (
 
 	pb "github.com/alexwilkerson/ddstats-server/gamesubmission"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
+// RunIDMetadataKey is the metadata key a game is submitted with the client's
+// local ID of its run under, as the request has no field for it.
+const RunIDMetadataKey = "ddstats-run-id"
+
 type Client struct {
 	gameRecorderClient pb.GameRecorderClient
 	conn               *grpc.ClientConn
@@ -28,9 +33,12 @@ func (c *Client) Close() {
 	c.conn.Close()
 }
 
-func (c *Client) SubmitGame(game *pb.SubmitGameRequest) (int, error) {
+// SubmitGame submits game, recorded in the local run runID, and returns the ID
+// the server gave it.
+func (c *Client) SubmitGame(game *pb.SubmitGameRequest, runID string) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, RunIDMetadataKey, runID)
 	r, err := c.gameRecorderClient.SubmitGame(ctx, game)
 	if err != nil {
 		return 0, fmt.Errorf("SubmitGame: failed to submit game over grpc: %w", err)
@@ -0,0 +1,49 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/alexwilkerson/ddstats-server/gamesubmission"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeServer records the run ID each game is submitted with.
+type fakeServer struct {
+	pb.UnimplementedGameRecorderServer
+	runIDs []string
+}
+
+func (s *fakeServer) SubmitGame(ctx context.Context, game *pb.SubmitGameRequest) (*pb.SubmitGameReply, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.runIDs = append(s.runIDs, md.Get(RunIDMetadataKey)...)
+	return &pb.SubmitGameReply{GameID: 42}, nil
+}
+
+func TestSubmitGameSendsRunID(t *testing.T) {
+	lis := bufconn.Listen(1 << 16)
+	srv := grpc.NewServer()
+	fake := &fakeServer{}
+	pb.RegisterGameRecorderServer(srv, fake)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{pb.NewGameRecorderClient(conn), conn}
+	defer c.Close()
+
+	gameID, err := c.SubmitGame(&pb.SubmitGameRequest{PlayerID: 21854}, "20260101T120000Z-0a1b2c3d")
+	if err != nil || gameID != 42 {
+		t.Fatalf("SubmitGame() = %d, %v, want 42, nil", gameID, err)
+	}
+	if len(fake.runIDs) != 1 || fake.runIDs[0] != "20260101T120000Z-0a1b2c3d" {
+		t.Errorf("server got run IDs %q, want the submitted run's", fake.runIDs)
+	}
+}
@@ -0,0 +1,55 @@
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Extension is the file extension the game gives local replays.
+const Extension = ".ddreplay"
+
+// IndexEntry is one replay file of a directory.
+type IndexEntry struct {
+	Path    string
+	ModTime time.Time
+	// Header is the file's header, or nil if it could not be read, in which case
+	// Err says why.
+	Header *Header
+	Err    error
+}
+
+// IndexDir reads the header of every replay file in dir. Files whose header
+// cannot be read are still listed, with their error. Entries are sorted by the
+// time their run was played, oldest first, followed by the unreadable files.
+func IndexDir(dir string) ([]IndexEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("IndexDir: could not read replay directory: %w", err)
+	}
+
+	var entries []IndexEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.EqualFold(filepath.Ext(f.Name()), Extension) {
+			continue
+		}
+		e := IndexEntry{
+			Path:    filepath.Join(dir, f.Name()),
+			ModTime: f.ModTime(),
+		}
+		e.Header, e.Err = ReadFile(e.Path)
+		entries = append(entries, e)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Header, entries[j].Header
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Timestamp < b.Timestamp
+	})
+	return entries, nil
+}
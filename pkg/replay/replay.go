@@ -0,0 +1,145 @@
+// Package replay reads the header of Devil Daggers local replay files, the
+// replays the game saves and downloads into its replays directory.
+package replay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// identifier starts every local replay file.
+const identifier = "ddrpl."
+
+// Sizes of the sections of a replay header.
+const (
+	// fixedHeaderSize is the size of the header up to the player name.
+	fixedHeaderSize = 54
+	// unknownSize is the size of the bytes between the player name and the
+	// spawnset hash, whose meaning is not known.
+	unknownSize = 10
+	md5Size     = 16
+	// maxNameLength bounds the player name length read from a file so a corrupt
+	// length cannot cause a huge read.
+	maxNameLength = 256
+	// maxHeaderSize is the most a header can take, and so the most ReadFile reads
+	// of a file.
+	maxHeaderSize = fixedHeaderSize + maxNameLength + unknownSize + md5Size
+)
+
+// gameRelease is the time the timestamps of replay headers count from.
+var gameRelease = time.Date(2016, time.February, 18, 0, 0, 0, 0, time.UTC)
+
+// Header is the header of a local replay file, which describes the run it
+// replays.
+type Header struct {
+	Version int32
+	// Timestamp is the number of seconds between the release of the game and the
+	// time the run was played.
+	Timestamp int64
+	// Time is the time the run ended at and StartTime the time its timer started
+	// at, which is not zero for spawnsets that start later.
+	Time         float32
+	StartTime    float32
+	DaggersFired int32
+	DeathType    devildaggers.DeathType
+	Gems         int32
+	DaggersHit   int32
+	Kills        int32
+	PlayerID     int32
+	PlayerName   string
+	// SpawnsetMD5 is the hash of the spawnset the run was played on, which is
+	// the level hash the game reports for it.
+	SpawnsetMD5 [16]byte
+}
+
+// ReadFile parses the header of the replay file at path, reading only as much of
+// the file as the header takes.
+func ReadFile(path string) (*Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: could not open replay: %w", err)
+	}
+	defer f.Close()
+
+	b := make([]byte, maxHeaderSize)
+	n, err := io.ReadFull(f, b)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("ReadFile: could not read replay: %w", err)
+	}
+	h, err := Parse(b[:n])
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: %w", err)
+	}
+	return h, nil
+}
+
+// Parse parses the header at the start of the contents of a replay file. b may
+// hold only the start of the file.
+func Parse(b []byte) (*Header, error) {
+	if len(b) < fixedHeaderSize || string(b[:len(identifier)]) != identifier {
+		return nil, errors.New("Parse: file is not a local replay")
+	}
+
+	h := &Header{
+		Version:      readInt32(b, 6),
+		Timestamp:    int64(binary.LittleEndian.Uint64(b[10:])),
+		Time:         readFloat32(b, 18),
+		StartTime:    readFloat32(b, 22),
+		DaggersFired: readInt32(b, 26),
+		Gems:         readInt32(b, 34),
+		DaggersHit:   readInt32(b, 38),
+		Kills:        readInt32(b, 42),
+		PlayerID:     readInt32(b, 46),
+	}
+	// A death type added by a newer build of the game is kept as DeathUnknown
+	// rather than making the replay unreadable.
+	h.DeathType, _ = devildaggers.ParseDeathType(int(readInt32(b, 30)))
+
+	nameLength := int(readInt32(b, 50))
+	if nameLength < 0 || nameLength > maxNameLength {
+		return nil, fmt.Errorf("Parse: invalid player name length %d", nameLength)
+	}
+	offset := fixedHeaderSize
+	if len(b) < offset+nameLength+unknownSize+md5Size {
+		return nil, errors.New("Parse: file is too short for its header")
+	}
+	h.PlayerName = string(b[offset : offset+nameLength])
+	offset += nameLength + unknownSize
+	copy(h.SpawnsetMD5[:], b[offset:offset+md5Size])
+
+	return h, nil
+}
+
+// RecordedAt returns the time the run was played.
+func (h *Header) RecordedAt() time.Time {
+	return gameRelease.Add(time.Duration(h.Timestamp) * time.Second)
+}
+
+// SpawnsetMD5String returns the spawnset hash in the format of
+// devildaggers.Snapshot.GetLevelHashMD5.
+func (h *Header) SpawnsetMD5String() string {
+	return fmt.Sprintf("%x", h.SpawnsetMD5)
+}
+
+// Accuracy returns the percentage of the daggers fired that hit an enemy.
+func (h *Header) Accuracy() float32 {
+	if h.DaggersFired == 0 {
+		return 0
+	}
+	return float32(h.DaggersHit) / float32(h.DaggersFired) * 100
+}
+
+func readInt32(b []byte, offset int) int32 {
+	return int32(binary.LittleEndian.Uint32(b[offset:]))
+}
+
+func readFloat32(b []byte, offset int) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(b[offset:]))
+}
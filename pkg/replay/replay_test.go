@@ -0,0 +1,168 @@
+package replay
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+var testSpawnsetMD5 = [16]byte{0x56, 0x9f, 0xea, 0xd8, 0x7a, 0xbf, 0x4d, 0x30, 0xfd, 0xee, 0x42, 0x31, 0xa6, 0x39, 0x80, 0x51}
+
+// testHeader is the header encoded by encodeHeader.
+func testHeader() Header {
+	return Header{
+		Version:      1,
+		Timestamp:    200000000,
+		Time:         512.3456,
+		StartTime:    0,
+		DaggersFired: 20000,
+		DeathType:    devildaggers.DeathEviscerated,
+		Gems:         400,
+		DaggersHit:   5000,
+		Kills:        1234,
+		PlayerID:     21854,
+		PlayerName:   "xvlv",
+		SpawnsetMD5:  testSpawnsetMD5,
+	}
+}
+
+// encodeHeader lays out h the way the game writes it at the start of a replay
+// file, followed by some replay data.
+func encodeHeader(h Header) []byte {
+	b := make([]byte, fixedHeaderSize)
+	copy(b, identifier)
+	binary.LittleEndian.PutUint32(b[6:], uint32(h.Version))
+	binary.LittleEndian.PutUint64(b[10:], uint64(h.Timestamp))
+	binary.LittleEndian.PutUint32(b[18:], math.Float32bits(h.Time))
+	binary.LittleEndian.PutUint32(b[22:], math.Float32bits(h.StartTime))
+	binary.LittleEndian.PutUint32(b[26:], uint32(h.DaggersFired))
+	binary.LittleEndian.PutUint32(b[30:], uint32(h.DeathType))
+	binary.LittleEndian.PutUint32(b[34:], uint32(h.Gems))
+	binary.LittleEndian.PutUint32(b[38:], uint32(h.DaggersHit))
+	binary.LittleEndian.PutUint32(b[42:], uint32(h.Kills))
+	binary.LittleEndian.PutUint32(b[46:], uint32(h.PlayerID))
+	binary.LittleEndian.PutUint32(b[50:], uint32(len(h.PlayerName)))
+	b = append(b, h.PlayerName...)
+	b = append(b, make([]byte, unknownSize)...)
+	b = append(b, h.SpawnsetMD5[:]...)
+	return append(b, "replay data"...)
+}
+
+func TestParse(t *testing.T) {
+	want := testHeader()
+	got, err := Parse(encodeHeader(want))
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if *got != want {
+		t.Errorf("Parse() = %+v, want %+v", *got, want)
+	}
+	if s := got.SpawnsetMD5String(); s != "569fead87abf4d30fdee4231a6398051" {
+		t.Errorf("SpawnsetMD5String() = %q", s)
+	}
+	if a := got.Accuracy(); a != 25 {
+		t.Errorf("Accuracy() = %v, want 25", a)
+	}
+	if at := got.RecordedAt(); !at.Equal(time.Date(2022, time.June, 20, 19, 33, 20, 0, time.UTC)) {
+		t.Errorf("RecordedAt() = %v", at)
+	}
+}
+
+func TestParseUnknownDeathType(t *testing.T) {
+	b := encodeHeader(testHeader())
+	binary.LittleEndian.PutUint32(b[30:], 200)
+	h, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse() = %v, want a header with an unknown death type", err)
+	}
+	if h.DeathType != devildaggers.DeathUnknown {
+		t.Errorf("DeathType = %v, want DeathUnknown", h.DeathType)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := encodeHeader(testHeader())
+	nameEnd := fixedHeaderSize + len(testHeader().PlayerName)
+	withNameLength := func(n int32) []byte {
+		b := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(b[50:], uint32(n))
+		return b
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"empty", nil, "not a local replay"},
+		{"truncated in the fixed header", valid[:fixedHeaderSize-1], "not a local replay"},
+		{"truncated in the player name", valid[:nameEnd-1], "too short"},
+		{"truncated in the spawnset hash", valid[:nameEnd+unknownSize+md5Size-1], "too short"},
+		{"bad identifier", append([]byte("ddrpl!"), valid[len(identifier):]...), "not a local replay"},
+		{"spawnset file", append([]byte{0x04, 0, 0, 0}, valid[4:]...), "not a local replay"},
+		{"oversize name length", withNameLength(maxNameLength + 1), "invalid player name length"},
+		{"huge name length", withNameLength(math.MaxInt32), "invalid player name length"},
+		{"negative name length", withNameLength(-1), "invalid player name length"},
+		{"name length past the end", withNameLength(maxNameLength), "too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Parse(tt.b)
+			if err == nil {
+				t.Fatalf("Parse() = %+v, want an error", h)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexDir(t *testing.T) {
+	dir := t.TempDir()
+	older, newer := testHeader(), testHeader()
+	older.Timestamp -= 3600
+	newer.PlayerName = "another player"
+	files := map[string][]byte{
+		"newer.ddreplay":    encodeHeader(newer),
+		"broken.ddreplay":   []byte("ddrpl."),
+		"older.DDREPLAY":    encodeHeader(older),
+		"notes.txt":         []byte("not a replay"),
+		"oversize.ddreplay": append(encodeHeader(older), make([]byte, 4*maxHeaderSize)...),
+	}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := IndexDir(dir)
+	if err != nil {
+		t.Fatalf("IndexDir() = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, filepath.Base(e.Path))
+	}
+	// Sorting is stable, so replays played at the same time stay in file name order.
+	want := []string{"older.DDREPLAY", "oversize.ddreplay", "newer.ddreplay", "broken.ddreplay"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Fatalf("IndexDir() lists %v, want %v", names, want)
+	}
+	if e := entries[2]; e.Err != nil || e.Header.PlayerName != "another player" {
+		t.Errorf("newer.ddreplay = %+v, %v", e.Header, e.Err)
+	}
+	if e := entries[3]; e.Header != nil || e.Err == nil {
+		t.Errorf("broken.ddreplay = %+v, %v, want an error", e.Header, e.Err)
+	}
+
+	if _, err := IndexDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("IndexDir() of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,63 @@
+package simulator
+
+import "github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+
+// The values below are rough approximations of the game, good enough for runs
+// that look plausible rather than for analysing real ones.
+
+// daggersPerSecond is how many daggers the hand fires per second at each level.
+var daggersPerSecond = [...]float64{
+	devildaggers.HandLevel1: 10,
+	devildaggers.HandLevel2: 20,
+	devildaggers.HandLevel3: 20,
+	devildaggers.HandLevel4: 30,
+}
+
+// lifetimes is the shortest and longest time an enemy survives after spawning.
+var lifetimes = [...][2]float64{
+	devildaggers.EnemySkull1:     {0.5, 3},
+	devildaggers.EnemySkull2:     {1, 4},
+	devildaggers.EnemySpiderling: {0.5, 2},
+	devildaggers.EnemySkull3:     {1, 5},
+	devildaggers.EnemySquid1:     {3, 12},
+	devildaggers.EnemySquid2:     {4, 16},
+	devildaggers.EnemySquid3:     {5, 20},
+	devildaggers.EnemyCentipede:  {10, 30},
+	devildaggers.EnemyGigapede:   {15, 45},
+	devildaggers.EnemySpider1:    {10, 40},
+	devildaggers.EnemySpider2:    {15, 50},
+	devildaggers.EnemyLeviathan:  {30, 90},
+	devildaggers.EnemyOrb:        {10, 30},
+	devildaggers.EnemyThorn:      {20, 60},
+	devildaggers.EnemyGhostpede:  {10, 30},
+	devildaggers.EnemySpiderEgg:  {2, 6},
+	devildaggers.EnemyUnnamed:    {1, 2},
+}
+
+// gemsDropped is how many gems an enemy drops when killed.
+var gemsDropped = [...]int32{
+	devildaggers.EnemySquid1:    1,
+	devildaggers.EnemySquid2:    2,
+	devildaggers.EnemySquid3:    3,
+	devildaggers.EnemyCentipede: 25,
+	devildaggers.EnemyGigapede:  50,
+	devildaggers.EnemySpider1:   1,
+	devildaggers.EnemySpider2:   1,
+	devildaggers.EnemyLeviathan: 6,
+	devildaggers.EnemyGhostpede: 10,
+	devildaggers.EnemyUnnamed:   0,
+}
+
+// skullsOf returns the skulls a squid spawns every skullInterval, or nil for
+// other enemies.
+func skullsOf(kind devildaggers.EnemyType) []devildaggers.EnemyType {
+	switch kind {
+	case devildaggers.EnemySquid1:
+		return []devildaggers.EnemyType{devildaggers.EnemySkull1, devildaggers.EnemySkull1}
+	case devildaggers.EnemySquid2:
+		return []devildaggers.EnemyType{devildaggers.EnemySkull1, devildaggers.EnemySkull1, devildaggers.EnemySkull2}
+	case devildaggers.EnemySquid3:
+		return []devildaggers.EnemyType{devildaggers.EnemySkull1, devildaggers.EnemySkull1, devildaggers.EnemySkull3}
+	}
+	return nil
+}
@@ -0,0 +1,448 @@
+// Package simulator generates plausible Devil Daggers runs and serves them
+// through a devildaggers.FakeProcess, so the client can be exercised without the
+// game. Runs are driven by a seeded random source and a fixed time step, so the
+// same seed and options always produce the same runs.
+package simulator
+
+import (
+	"context"
+	"crypto/md5"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+	"github.com/alexwilkerson/ddstats-go/pkg/spawnset"
+)
+
+const (
+	// step is the game time simulated at a time, whatever the caller's tick.
+	step = 1.0 / 60
+	// Seconds spent in each screen around a run.
+	titleDuration = 2
+	lobbyDuration = 3
+	deadDuration  = 6
+	// statsLoadDelay is how long after death the game finishes loading stats.
+	statsLoadDelay = 0.5
+	// skullInterval is how often squids spawn skulls.
+	skullInterval = 3
+)
+
+// Hash is the level hash reported for runs without a spawnset, the MD5 of
+// "ddstats simulator" so that it never matches a real spawnset.
+var Hash = md5.Sum([]byte("ddstats simulator"))
+
+// Options configures the runs a Simulator plays.
+type Options struct {
+	// Seed seeds the random source every run is generated from.
+	Seed int64
+	// Spawnset decides which enemies spawn when, and the hand level, gems and
+	// time runs start with. If it is nil a built-in schedule is used.
+	Spawnset *spawnset.Spawnset
+	// DeathTime is the time the player dies at. If it is 0 a random time is
+	// picked for every run.
+	DeathTime float32
+	// DeathType is how the player dies. devildaggers.DeathUnknown picks the
+	// death of a random enemy alive at the time of death.
+	DeathType devildaggers.DeathType
+	// Loop plays a new run after each death. Otherwise the simulator stays dead.
+	Loop       bool
+	PlayerID   int32
+	PlayerName string
+}
+
+// DefaultOptions returns options playing random runs forever.
+func DefaultOptions(seed int64) Options {
+	return Options{
+		Seed:       seed,
+		DeathType:  devildaggers.DeathUnknown,
+		Loop:       true,
+		PlayerID:   1,
+		PlayerName: "ddstats demo",
+	}
+}
+
+type phase int
+
+const (
+	phaseTitle phase = iota
+	phaseLobby
+	phasePlaying
+	phaseDead
+)
+
+// enemy is an enemy alive in the arena.
+type enemy struct {
+	kind      devildaggers.EnemyType
+	diesAt    float64
+	nextSpawn float64
+}
+
+// Simulator plays generated runs on a FakeProcess, which it embeds so that it
+// can be used as a devildaggers.ProcessLocator. Step and Run must not be called
+// concurrently.
+type Simulator struct {
+	*devildaggers.FakeProcess
+	opts Options
+	rng  *rand.Rand
+
+	phase      phase
+	phaseStart float64
+	clock      float64
+	pending    float64
+
+	block     devildaggers.DataBlock
+	frames    []devildaggers.StatsFrame
+	framesSet int
+	runTime   float64
+	deathTime float64
+	schedule  []spawnset.ScheduledSpawn
+	enemies   []enemy
+	start     devildaggers.HandStart
+	fired     float64
+	hitRatio  float64
+	hits      float64
+}
+
+// New returns a Simulator on the title screen.
+func New(opts Options) *Simulator {
+	if opts.PlayerName == "" {
+		opts.PlayerName = DefaultOptions(0).PlayerName
+	}
+	s := &Simulator{
+		FakeProcess: devildaggers.NewFakeProcess(),
+		opts:        opts,
+		rng:         rand.New(rand.NewSource(opts.Seed)),
+	}
+	s.block = devildaggers.DataBlock{
+		DDStatsVersion: 1,
+		PlayerID:       opts.PlayerID,
+		Status:         devildaggers.StatusTitle,
+	}
+	copy(s.block.UserName[:], opts.PlayerName)
+	s.publish()
+	return s
+}
+
+// Run steps the simulator in real time, speed game seconds per second, until
+// ctx is cancelled.
+func (s *Simulator) Run(ctx context.Context, speed float64) {
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Step(time.Duration(float64(now.Sub(last)) * speed))
+			last = now
+		}
+	}
+}
+
+// Step advances the simulation by d of game time and updates the fake process.
+func (s *Simulator) Step(d time.Duration) {
+	s.pending += d.Seconds()
+	for s.pending >= step {
+		s.pending -= step
+		s.clock += step
+		s.tick()
+	}
+	s.publish()
+}
+
+func (s *Simulator) tick() {
+	elapsed := s.clock - s.phaseStart
+	switch s.phase {
+	case phaseTitle:
+		if elapsed >= titleDuration {
+			s.enter(phaseLobby)
+			s.block.Status = devildaggers.StatusLobby
+		}
+	case phaseLobby:
+		if elapsed >= lobbyDuration {
+			s.startRun()
+		}
+	case phasePlaying:
+		s.play()
+	case phaseDead:
+		if elapsed >= statsLoadDelay {
+			s.block.StatsFinishedLoading = true
+		}
+		if elapsed >= deadDuration && s.opts.Loop {
+			s.enter(phaseLobby)
+			s.block.Status = devildaggers.StatusLobby
+			s.block.IsInGame = false
+		}
+	}
+}
+
+func (s *Simulator) enter(p phase) {
+	s.phase = p
+	s.phaseStart = s.clock
+}
+
+// startRun resets the block for a new run and plans it.
+func (s *Simulator) startRun() {
+	s.enter(phasePlaying)
+
+	s.start = devildaggers.HandStart{Level: devildaggers.HandLevel1}
+	var timerStart float32
+	hash := Hash
+	if ss := s.opts.Spawnset; ss != nil {
+		s.start = ss.HandStart()
+		timerStart = ss.TimerStart
+		hash = ss.MD5()
+	}
+
+	s.deathTime = float64(s.opts.DeathTime)
+	if s.deathTime <= 0 {
+		s.deathTime = 20 + s.rng.ExpFloat64()*120
+	}
+	if ss := s.opts.Spawnset; ss != nil {
+		s.schedule = ss.Timeline(s.deathTime)
+	} else {
+		s.schedule = defaultSchedule(s.deathTime)
+	}
+
+	s.block = devildaggers.DataBlock{
+		DDStatsVersion:    s.block.DDStatsVersion,
+		PlayerID:          s.block.PlayerID,
+		UserName:          s.block.UserName,
+		Status:            devildaggers.StatusPlaying,
+		IsPlayerAlive:     true,
+		IsInGame:          true,
+		LevelHashMD5:      hash,
+		StartingHandLevel: int32(s.start.Level),
+		StartingTime:      timerStart,
+	}
+	s.frames = nil
+	s.framesSet = -1
+	s.runTime = 0
+	s.enemies = s.enemies[:0]
+	s.fired, s.hits = 0, 0
+	s.hitRatio = 0.2 + s.rng.Float64()*0.2
+	s.recordFrame()
+}
+
+// play simulates one step of the run.
+func (s *Simulator) play() {
+	b := &s.block
+	s.runTime += step
+	b.Time = float32(s.runTime)
+	level := s.handLevel()
+
+	for len(s.schedule) > 0 && s.schedule[0].Second-float64(b.StartingTime) <= s.runTime {
+		s.spawn(s.schedule[0].Enemy)
+		s.schedule = s.schedule[1:]
+	}
+
+	alive := s.enemies[:0]
+	var spawned []devildaggers.EnemyType
+	for _, e := range s.enemies {
+		if e.diesAt <= s.runTime {
+			s.kill(e.kind)
+			continue
+		}
+		if e.nextSpawn != 0 && e.nextSpawn <= s.runTime {
+			e.nextSpawn += skullInterval
+			spawned = append(spawned, skullsOf(e.kind)...)
+		}
+		alive = append(alive, e)
+	}
+	s.enemies = alive
+	for _, kind := range spawned {
+		s.spawn(kind)
+	}
+
+	s.fired += daggersPerSecond[level] * step
+	s.hits += daggersPerSecond[level] * step * s.hitRatio
+	b.DaggersFired = int32(s.fired)
+	b.DaggersHit = int32(s.hits)
+
+	if b.HomingDaggers > 0 && s.rng.Float64() < 0.1*step {
+		used := int32(float64(b.HomingDaggers) * (0.3 + s.rng.Float64()*0.7))
+		b.HomingDaggers -= used
+		s.fired += float64(used)
+		s.hits += float64(used) * 0.9
+	}
+
+	b.EnemiesAlive = int32(len(s.enemies))
+	if b.EnemiesAlive > b.EnemiesAliveMax {
+		b.EnemiesAliveMax = b.EnemiesAlive
+		b.TimeEnemiesAliveMax = b.Time
+	}
+	if b.HomingDaggers > b.HomingMax {
+		b.HomingMax = b.HomingDaggers
+		b.TimeHomingMax = b.Time
+	}
+	b.TimeMax = b.Time
+	b.TotalGems = b.GemsCollected + b.GemsDespawned + b.GemsEaten
+
+	if int(s.runTime) >= len(s.frames) {
+		s.recordFrame()
+	}
+
+	if s.runTime >= s.deathTime {
+		s.die()
+	}
+}
+
+func (s *Simulator) spawn(kind devildaggers.EnemyType) {
+	life := lifetimes[kind]
+	e := enemy{kind: kind, diesAt: s.runTime + life[0] + s.rng.Float64()*(life[1]-life[0])}
+	if len(skullsOf(kind)) > 0 {
+		e.nextSpawn = s.runTime + skullInterval
+	}
+	s.enemies = append(s.enemies, e)
+	s.block.PerEnemyAliveCount[kind]++
+}
+
+// kill removes an enemy and drops its gems, most of which are collected.
+func (s *Simulator) kill(kind devildaggers.EnemyType) {
+	b := &s.block
+	b.Kills++
+	b.PerEnemyAliveCount[kind]--
+	b.PerEnemyKillCount[kind]++
+	if kind == devildaggers.EnemyLeviathan && b.LeviDownTime == 0 {
+		b.LeviDownTime = b.Time
+	}
+
+	for i := int32(0); i < gemsDropped[kind]; i++ {
+		switch r := s.rng.Float64(); {
+		case r < 0.85:
+			s.collectGem()
+		case r < 0.95:
+			b.GemsDespawned++
+		default:
+			b.GemsEaten++
+		}
+	}
+}
+
+// collectGem adds a gem, levelling the hand up at the thresholds and adding
+// homing daggers from level 3.
+func (s *Simulator) collectGem() {
+	b := &s.block
+	before := s.handLevel()
+	b.GemsCollected++
+	b.LevelGems = b.GemsCollected
+	after := s.handLevel()
+	b.HomingDaggers += after.Info().HomingPerGem
+
+	for level := before + 1; level <= after; level++ {
+		switch level {
+		case devildaggers.HandLevel2:
+			b.TimeLvl2 = b.Time
+		case devildaggers.HandLevel3:
+			b.TimeLvl3 = b.Time
+		case devildaggers.HandLevel4:
+			b.TimeLvl4 = b.Time
+		}
+	}
+}
+
+// handLevel returns the level of the hand from the gems collected so far.
+func (s *Simulator) handLevel() devildaggers.HandLevel {
+	level := devildaggers.HandLevelForGems(s.start.Gems() + s.block.GemsCollected)
+	if level < s.start.Level {
+		level = s.start.Level
+	}
+	return level
+}
+
+func (s *Simulator) die() {
+	b := &s.block
+	b.Status = devildaggers.StatusDead
+	b.IsPlayerAlive = false
+	b.DeathType = uint8(s.deathType())
+	s.recordFrame()
+	s.enter(phaseDead)
+}
+
+// deathType returns the configured death type, or the death of a random enemy
+// alive at the time of death.
+func (s *Simulator) deathType() devildaggers.DeathType {
+	if s.opts.DeathType.Valid() {
+		return s.opts.DeathType
+	}
+	var candidates []devildaggers.DeathType
+	for d := devildaggers.DeathType(0); d.Valid(); d++ {
+		for _, kind := range d.Enemies() {
+			if s.block.PerEnemyAliveCount[kind] > 0 {
+				candidates = append(candidates, d)
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return devildaggers.DeathFallen
+	}
+	return candidates[s.rng.Intn(len(candidates))]
+}
+
+// recordFrame appends the state of the run as a stats frame, which the game does
+// once a second and when the player dies.
+func (s *Simulator) recordFrame() {
+	b := &s.block
+	s.frames = append(s.frames, devildaggers.StatsFrame{
+		GemsCollected:      b.GemsCollected,
+		Kills:              b.Kills,
+		DaggersFired:       b.DaggersFired,
+		DaggersHit:         b.DaggersHit,
+		EnemiesAlive:       b.EnemiesAlive,
+		LevelGems:          b.LevelGems,
+		HomingDaggers:      b.HomingDaggers,
+		GemsDespawned:      b.GemsDespawned,
+		GemsEaten:          b.GemsEaten,
+		TotalGems:          b.TotalGems,
+		DaggersEaten:       b.DaggersEaten,
+		PerEnemyAliveCount: b.PerEnemyAliveCount,
+		PerEnemyKillCount:  b.PerEnemyKillCount,
+	})
+	b.StatsFramesLoaded = int32(len(s.frames))
+}
+
+// publish writes the simulated state to the fake process.
+func (s *Simulator) publish() {
+	if len(s.frames) != s.framesSet {
+		s.SetStatsFrames(s.frames)
+		s.framesSet = len(s.frames)
+	}
+	s.SetDataBlock(s.block)
+}
+
+// Block returns the state of the simulated block.
+func (s *Simulator) Block() devildaggers.DataBlock {
+	return s.block
+}
+
+// defaultSchedule is the spawn schedule of runs without a spawnset, loosely
+// following the pace of the game's survival spawnset.
+func defaultSchedule(until float64) []spawnset.ScheduledSpawn {
+	waves := []struct {
+		enemy           devildaggers.EnemyType
+		first, interval float64
+	}{
+		{devildaggers.EnemySquid1, 3, 5},
+		{devildaggers.EnemySquid2, 15, 10},
+		{devildaggers.EnemyCentipede, 40, 60},
+		{devildaggers.EnemySpider1, 60, 30},
+		{devildaggers.EnemySquid3, 80, 20},
+		{devildaggers.EnemyGigapede, 140, 70},
+		{devildaggers.EnemyThorn, 180, 40},
+		{devildaggers.EnemySpider2, 200, 45},
+		{devildaggers.EnemyGhostpede, 250, 90},
+		{devildaggers.EnemyLeviathan, 350, math.Inf(1)},
+	}
+	var schedule []spawnset.ScheduledSpawn
+	for _, w := range waves {
+		for t := w.first; t <= until; t += w.interval {
+			schedule = append(schedule, spawnset.ScheduledSpawn{Second: t, Enemy: w.enemy})
+		}
+	}
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].Second < schedule[j].Second })
+	return schedule
+}
@@ -0,0 +1,78 @@
+package simulator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// simulate steps a new simulator with opts for the given game time and returns
+// the block after every step, and the frames of every run played.
+func simulate(opts Options, duration time.Duration) ([]devildaggers.DataBlock, [][]devildaggers.StatsFrame) {
+	s := New(opts)
+	var blocks []devildaggers.DataBlock
+	var runs [][]devildaggers.StatsFrame
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += 250 * time.Millisecond {
+		frames, status := s.frames, s.block.Status
+		s.Step(250 * time.Millisecond)
+		if status != devildaggers.StatusPlaying && s.block.Status == devildaggers.StatusPlaying && frames != nil {
+			runs = append(runs, frames)
+		}
+		blocks = append(blocks, s.Block())
+	}
+	return blocks, append(runs, s.frames)
+}
+
+func TestSameSeedSameRuns(t *testing.T) {
+	opts := DefaultOptions(1234)
+	blocks, runs := simulate(opts, 15*time.Minute)
+	if len(runs) < 3 {
+		t.Fatalf("%d runs played, want a few to compare", len(runs))
+	}
+
+	againBlocks, againRuns := simulate(opts, 15*time.Minute)
+	for i := range blocks {
+		if blocks[i] != againBlocks[i] {
+			t.Fatalf("block after step %d differs:\n%+v\n%+v", i, blocks[i], againBlocks[i])
+		}
+	}
+	if !reflect.DeepEqual(runs, againRuns) {
+		t.Error("stats frames differ between two simulators with the same seed")
+	}
+
+	otherBlocks, _ := simulate(DefaultOptions(4321), 15*time.Minute)
+	if reflect.DeepEqual(blocks, otherBlocks) {
+		t.Error("simulators with different seeds played the same runs")
+	}
+}
+
+func TestRunsReadByClient(t *testing.T) {
+	opts := DefaultOptions(99)
+	opts.DeathTime = 30
+	opts.Loop = false
+	s := New(opts)
+	dd := devildaggers.NewWithLocator(s)
+	if connected, err := dd.Connect(); !connected || err != nil {
+		t.Fatalf("Connect() = %v, %v, want true, nil", connected, err)
+	}
+
+	for i := 0; i < 60*60; i++ {
+		s.Step(time.Second / 60)
+		if err := dd.RefreshData(); err != nil {
+			t.Fatalf("RefreshData() after %d steps = %v", i, err)
+		}
+	}
+
+	snap := dd.Snapshot()
+	if snap.GetStatus() != devildaggers.StatusDead || !snap.GetStatsFinishedLoading() {
+		t.Fatalf("status %d with stats loaded %v, want a dead run with its stats", snap.GetStatus(), snap.GetStatsFinishedLoading())
+	}
+	if got := snap.GetTime(); got < 29.9 || got > 30.1 {
+		t.Errorf("GetTime() = %v, want the death time 30", got)
+	}
+	if !reflect.DeepEqual(snap.GetStatsFrame(), s.frames) {
+		t.Error("stats frames read by the client differ from the simulated ones")
+	}
+}
@@ -121,37 +121,48 @@ type SubmissionData struct {
 	NotifyPlayerBest bool
 	NotifyAbove1000  bool
 	DeathScreenSent  bool
+	// RunID is the client's local ID of the run the stats are from.
+	RunID string
+}
+
+// emitArgs returns the arguments the stats are sent to the server with. The run
+// ID comes last so that servers reading only the earlier ones are unaffected.
+func (d *SubmissionData) emitArgs() []interface{} {
+	return []interface{}{
+		d.PlayerID,
+		d.Timer,
+		d.TotalGems,
+		d.Homing,
+		d.EnemiesAlive,
+		d.EnemiesKilled,
+		d.DaggersHit,
+		d.DaggersFired,
+		d.Level2time,
+		d.Level3time,
+		d.Level4time,
+		d.IsReplay,
+		d.DeathType,
+		d.NotifyPlayerBest,
+		d.NotifyAbove1000,
+		d.RunID,
+	}
 }
 
 func (c *Client) SubmitStats(submissionData *SubmissionData) error {
 	if c.sioClient == nil {
 		return errors.New("SubmitStats: sioClient is nil")
 	}
-	err := c.sioClient.Emit(
-		submitFuncName,
-		submissionData.PlayerID,
-		submissionData.Timer,
-		submissionData.TotalGems,
-		submissionData.Homing,
-		submissionData.EnemiesAlive,
-		submissionData.EnemiesKilled,
-		submissionData.DaggersHit,
-		submissionData.DaggersFired,
-		submissionData.Level2time,
-		submissionData.Level3time,
-		submissionData.Level4time,
-		submissionData.IsReplay,
-		submissionData.DeathType,
-		submissionData.NotifyPlayerBest,
-		submissionData.NotifyAbove1000,
-	)
+	err := c.sioClient.Emit(submitFuncName, submissionData.emitArgs()...)
 	if err != nil {
 		return fmt.Errorf("SubmitStats: error submitting: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) SubmitGame(gameID int, notifyPlayerBest, notifyAbove1000 bool) error {
+// SubmitGame tells the server the game with gameID, recorded in the local run
+// runID, was submitted. The run ID comes last so that servers reading only the
+// earlier arguments are unaffected.
+func (c *Client) SubmitGame(gameID int, runID string, notifyPlayerBest, notifyAbove1000 bool) error {
 	if c.sioClient == nil {
 		return errors.New("SubmitGame: sioClient is nil")
 	}
@@ -160,6 +171,7 @@ func (c *Client) SubmitGame(gameID int, notifyPlayerBest, notifyAbove1000 bool)
 		gameID,
 		notifyPlayerBest,
 		notifyAbove1000,
+		runID,
 	)
 	if err != nil {
 		return fmt.Errorf("SubmitGame: error sending 'game_submitted' func via sio: %w", err)
@@ -0,0 +1,17 @@
+package socketio
+
+import "testing"
+
+func TestSubmissionDataEmitArgs(t *testing.T) {
+	d := SubmissionData{PlayerID: 21854, Timer: 512.5, DeathType: 3, NotifyAbove1000: true, RunID: "20260101T120000Z-0a1b2c3d"}
+	args := d.emitArgs()
+	if len(args) != 16 {
+		t.Fatalf("%d arguments, want 16", len(args))
+	}
+	if args[0] != int32(21854) || args[1] != float32(512.5) || args[12] != int32(3) || args[14] != true {
+		t.Errorf("emitArgs() = %v, want the stats in their original order", args)
+	}
+	if args[15] != d.RunID {
+		t.Errorf("last argument = %v, want the run ID %q", args[15], d.RunID)
+	}
+}
@@ -0,0 +1,267 @@
+package spawnset
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// Category is what a spawnset is played for.
+type Category int
+
+const (
+	CategoryUnknown Category = iota
+	CategorySurvival
+	CategoryPractice
+	CategoryRace
+	CategoryCustom
+)
+
+var categoryNames = [...]string{"unknown", "survival", "practice", "race", "custom"}
+
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return "unknown"
+	}
+	return categoryNames[c]
+}
+
+// ParseCategory returns the category with the given name.
+func ParseCategory(name string) (Category, error) {
+	for i, n := range categoryNames {
+		if strings.EqualFold(name, n) {
+			return Category(i), nil
+		}
+	}
+	return CategoryUnknown, fmt.Errorf("ParseCategory: unknown spawnset category %q", name)
+}
+
+// UnmarshalText lets categories be written by name in registry files.
+func (c *Category) UnmarshalText(text []byte) error {
+	category, err := ParseCategory(string(text))
+	if err != nil {
+		return err
+	}
+	*c = category
+	return nil
+}
+
+// RegistryFile is the name of the file in a user spawnset directory that adds
+// metadata to the registry.
+const RegistryFile = "spawnsets.toml"
+
+//go:embed registry.toml
+var bundledRegistry string
+
+// Entry describes a known spawnset.
+type Entry struct {
+	// Hash is the MD5 of the spawnset file in the format of
+	// devildaggers.Snapshot.GetLevelHashMD5.
+	Hash     string   `toml:"hash"`
+	Name     string   `toml:"name"`
+	Author   string   `toml:"author"`
+	Category Category `toml:"category"`
+	Notes    string   `toml:"notes"`
+	// Path is the spawnset file the entry was read from, if any.
+	Path string `toml:"-"`
+}
+
+// Registry maps level hashes to the spawnsets they belong to. Once loaded, it is
+// safe for concurrent lookups.
+type Registry struct {
+	entries map[string]Entry
+	// starts holds the hand starts of the spawnset files read by LoadDir.
+	starts map[string]devildaggers.HandStart
+	// bundled holds the hashes of the spawnsets bundled with the client, whose
+	// metadata user registry files cannot replace.
+	bundled map[string]bool
+}
+
+// LoadError is returned when some files or entries could not be added to the
+// registry. Everything else was added.
+type LoadError struct {
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return "spawnsets skipped: " + strings.Join(e.Problems, "; ")
+}
+
+// NewRegistry returns a registry of the spawnsets bundled with the client.
+func NewRegistry() (*Registry, error) {
+	r := &Registry{
+		entries: make(map[string]Entry),
+		starts:  make(map[string]devildaggers.HandStart),
+		bundled: make(map[string]bool),
+	}
+	problems, err := r.load(bundledRegistry)
+	if err == nil && problems != nil {
+		err = errors.New(strings.Join(problems, "; "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("NewRegistry: could not load bundled registry: %w", err)
+	}
+	for hash := range r.entries {
+		r.bundled[hash] = true
+	}
+	return r, nil
+}
+
+// LoadFile adds the entries of a registry file, replacing the metadata of
+// spawnsets already known, except for those bundled with the client. Entries
+// that cannot be added are skipped and reported in a *LoadError.
+func (r *Registry) LoadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("LoadFile: could not read registry file: %w", err)
+	}
+	problems, err := r.load(string(b))
+	if err != nil {
+		return fmt.Errorf("LoadFile: %s: %w", path, err)
+	}
+	if problems != nil {
+		for i := range problems {
+			problems[i] = path + ": " + problems[i]
+		}
+		return fmt.Errorf("LoadFile: %w", &LoadError{Problems: problems})
+	}
+	return nil
+}
+
+// load adds the entries of a registry file's contents. err is set if the file
+// cannot be decoded, and problems lists the entries skipped.
+func (r *Registry) load(data string) (problems []string, err error) {
+	var file struct {
+		Spawnsets []Entry `toml:"spawnset"`
+	}
+	if _, err := toml.Decode(data, &file); err != nil {
+		return nil, err
+	}
+	for _, e := range file.Spawnsets {
+		e.Hash = strings.ToLower(e.Hash)
+		if e.Hash == "" {
+			problems = append(problems, fmt.Sprintf("spawnset %q has no hash", e.Name))
+			continue
+		}
+		existing, ok := r.entries[e.Hash]
+		if ok && r.bundled[e.Hash] {
+			problems = append(problems, fmt.Sprintf("spawnset %q has the hash of the bundled spawnset %q", e.Name, existing.Name))
+			continue
+		}
+		if ok {
+			e.Path = existing.Path
+		}
+		r.entries[e.Hash] = e
+	}
+	return problems, nil
+}
+
+// LoadDir adds every spawnset file in dir, named after the file and in the
+// custom category, then the metadata of the dir's registry file if it has one.
+// Files that are not spawnsets, a registry file that cannot be read and entries
+// that cannot be added are skipped, and reported in a *LoadError once the rest
+// is loaded. A missing directory is not an error.
+func (r *Registry) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("LoadDir: could not read spawnset directory: %w", err)
+	}
+
+	var problems []string
+	for _, f := range files {
+		if f.IsDir() || f.Name() == RegistryFile {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		s, err := ReadFile(path)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		hash := s.MD5String()
+		e, ok := r.entries[hash]
+		if !ok {
+			e = Entry{
+				Hash:     hash,
+				Name:     strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())),
+				Category: CategoryCustom,
+			}
+		}
+		e.Path = path
+		r.entries[hash] = e
+		r.starts[hash] = s.HandStart()
+	}
+
+	registryPath := filepath.Join(dir, RegistryFile)
+	if _, err := os.Stat(registryPath); err == nil {
+		if err := r.LoadFile(registryPath); err != nil {
+			var loadErr *LoadError
+			if errors.As(err, &loadErr) {
+				problems = append(problems, loadErr.Problems...)
+			} else {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	if problems != nil {
+		return fmt.Errorf("LoadDir: %w", &LoadError{Problems: problems})
+	}
+	return nil
+}
+
+// Lookup returns the entry for a level hash.
+func (r *Registry) Lookup(hash string) (Entry, bool) {
+	e, ok := r.entries[strings.ToLower(hash)]
+	return e, ok
+}
+
+// Name returns the name of the spawnset with the given hash, or a placeholder
+// for unknown spawnsets.
+func (r *Registry) Name(hash string) string {
+	if e, ok := r.Lookup(hash); ok && e.Name != "" {
+		return e.Name
+	}
+	return "Unknown spawnset"
+}
+
+// Category returns the category of the spawnset with the given hash, or
+// CategoryUnknown for unknown spawnsets.
+func (r *Registry) Category(hash string) Category {
+	e, _ := r.Lookup(hash)
+	return e.Category
+}
+
+// HandStart returns the hand level and additional gems of the spawnset with the
+// given level hash, if its file was read. It is a devildaggers.HandStartResolver.
+func (r *Registry) HandStart(levelHash [16]byte) (devildaggers.HandStart, bool) {
+	start, ok := r.starts[hex.EncodeToString(levelHash[:])]
+	return start, ok
+}
+
+// Entries returns every known spawnset, sorted by name.
+func (r *Registry) Entries() []Entry {
+	entries := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Hash < entries[j].Hash
+	})
+	return entries
+}
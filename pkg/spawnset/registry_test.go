@@ -0,0 +1,151 @@
+package spawnset
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+const v3Hash = "569fead87abf4d30fdee4231a6398051"
+
+func TestBundledRegistry(t *testing.T) {
+	r, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry() = %v", err)
+	}
+	for _, hash := range []string{v3Hash, strings.ToUpper(v3Hash)} {
+		e, ok := r.Lookup(hash)
+		if !ok || e.Name != "V3 Survival" || e.Author != "Sorath" || e.Category != CategorySurvival {
+			t.Errorf("Lookup(%s) = %+v, %v, want V3", hash, e, ok)
+		}
+	}
+	if name, category := r.Name("0123"), r.Category("0123"); name != "Unknown spawnset" || category != CategoryUnknown {
+		t.Errorf("Name() and Category() of an unknown hash = %q, %s", name, category)
+	}
+}
+
+// writeFile writes a file into dir, failing the test if it cannot.
+func writeFile(t *testing.T, dir, name string, b []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDir(t *testing.T) {
+	custom := buildSpawnset(6, GameModeSurvival, []testSpawn{{0, 3}}, testSettings(3, 12, 0))
+	s, err := Parse(custom)
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	hash := s.MD5String()
+
+	tests := []struct {
+		name     string
+		registry string
+		extra    bool
+		wantName string
+		wantErr  []string
+	}{
+		{"spawnset file alone", "", false, "my spawnset", nil},
+		{"renamed by the registry file", "[[spawnset]]\nhash = \"" + strings.ToUpper(hash) + "\"\nname = \"Renamed\"\ncategory = \"practice\"\n", false, "Renamed", nil},
+		{"bundled hash in the registry file", "[[spawnset]]\nhash = \"" + strings.ToUpper(v3Hash) + "\"\nname = \"Not V3\"\n", false, "my spawnset",
+			[]string{"spawnset \"Not V3\" has the hash of the bundled spawnset \"V3 Survival\""}},
+		{"entry without a hash", "[[spawnset]]\nname = \"Nameless\"\n", false, "my spawnset", []string{"spawnset \"Nameless\" has no hash"}},
+		{"broken registry file", "[[spawnset]\n", false, "my spawnset", []string{RegistryFile}},
+		{"file that is not a spawnset", "", true, "my spawnset", []string{"notes.txt", "too short to be a spawnset"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := writeFile(t, dir, "my spawnset.dat", custom)
+			if tt.registry != "" {
+				writeFile(t, dir, RegistryFile, []byte(tt.registry))
+			}
+			if tt.extra {
+				writeFile(t, dir, "notes.txt", []byte("hello"))
+			}
+
+			r, err := NewRegistry()
+			if err != nil {
+				t.Fatalf("NewRegistry() = %v", err)
+			}
+			err = r.LoadDir(dir)
+			var loadErr *LoadError
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("LoadDir() = %v, want nil", err)
+			}
+			if tt.wantErr != nil {
+				if !errors.As(err, &loadErr) || len(loadErr.Problems) != 1 {
+					t.Fatalf("LoadDir() = %v, want a LoadError with one problem", err)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(loadErr.Problems[0], want) {
+						t.Errorf("LoadDir() problem = %q, want it to contain %q", loadErr.Problems[0], want)
+					}
+				}
+			}
+
+			// The spawnset file is loaded whatever else was skipped.
+			e, ok := r.Lookup(hash)
+			if !ok || e.Name != tt.wantName || e.Path != path {
+				t.Errorf("Lookup() = %+v, %v, want %q at %s", e, ok, tt.wantName, path)
+			}
+			if start, ok := r.HandStart(s.MD5()); !ok || start != (devildaggers.HandStart{Level: devildaggers.HandLevel3, AdditionalGems: 12}) {
+				t.Errorf("HandStart() = %+v, %v", start, ok)
+			}
+			if v3, _ := r.Lookup(v3Hash); v3.Name != "V3 Survival" || v3.Category != CategorySurvival {
+				t.Errorf("bundled V3 entry = %+v after loading the directory", v3)
+			}
+		})
+	}
+
+	t.Run("custom category", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, dir, "my spawnset.dat", custom)
+		r, _ := NewRegistry()
+		if err := r.LoadDir(dir); err != nil {
+			t.Fatalf("LoadDir() = %v", err)
+		}
+		if c := r.Category(hash); c != CategoryCustom {
+			t.Errorf("Category() = %s, want custom", c)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		r, _ := NewRegistry()
+		if err := r.LoadDir(filepath.Join(t.TempDir(), "missing")); err != nil {
+			t.Errorf("LoadDir() of a missing directory = %v, want nil", err)
+		}
+	})
+}
+
+func TestParseCategory(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Category
+		wantErr bool
+	}{
+		{"survival", CategorySurvival, false},
+		{"Practice", CategoryPractice, false},
+		{"RACE", CategoryRace, false},
+		{"custom", CategoryCustom, false},
+		{"unknown", CategoryUnknown, false},
+		{"speedrun", CategoryUnknown, true},
+		{"", CategoryUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCategory(tt.name)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("ParseCategory(%q) = %s, %v, want %s and error %v", tt.name, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
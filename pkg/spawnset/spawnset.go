@@ -0,0 +1,226 @@
+// Package spawnset reads Devil Daggers spawnset files, the "survival" files
+// that define the arena, the enemies that spawn and the run's starting settings.
+package spawnset
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// ArenaSize is the width and height of the arena in tiles.
+const ArenaSize = 51
+
+// Sizes of the sections of a spawnset file.
+const (
+	headerSize       = 36
+	arenaBytes       = ArenaSize * ArenaSize * 4
+	spawnsHeaderSize = 40
+	spawnSize        = 28
+	// maxSpawns bounds the spawn count read from a file so a corrupt count
+	// cannot cause a huge allocation.
+	maxSpawns = 1 << 16
+)
+
+// GameMode is how a spawnset is played.
+type GameMode int32
+
+const (
+	GameModeSurvival GameMode = iota
+	GameModeTimeAttack
+	GameModeRace
+)
+
+var gameModeNames = [...]string{"Survival", "Time Attack", "Race"}
+
+func (m GameMode) String() string {
+	if m < 0 || int(m) >= len(gameModeNames) {
+		return "Unknown"
+	}
+	return gameModeNames[m]
+}
+
+// enemyCodes maps the enemy codes used in spawnset files to enemy types. Skulls,
+// spiderlings and spider eggs are spawned by other enemies, not by the spawnset.
+var enemyCodes = [...]devildaggers.EnemyType{
+	0: devildaggers.EnemySquid1,
+	1: devildaggers.EnemySquid2,
+	2: devildaggers.EnemySquid3,
+	3: devildaggers.EnemyLeviathan,
+	4: devildaggers.EnemySpider1,
+	5: devildaggers.EnemySpider2,
+	6: devildaggers.EnemyCentipede,
+	7: devildaggers.EnemyGigapede,
+	8: devildaggers.EnemyGhostpede,
+	9: devildaggers.EnemyThorn,
+}
+
+// emptyCode is the enemy code of a spawn that spawns nothing and only waits.
+const emptyCode = -1
+
+// Spawn is one entry of the spawn list.
+type Spawn struct {
+	// Enemy is the enemy spawned. It is meaningless if Empty is set.
+	Enemy devildaggers.EnemyType
+	// Empty is set for entries that spawn nothing and only add their delay.
+	Empty bool
+	// Delay is the time in seconds since the previous spawn.
+	Delay float32
+}
+
+// Spawnset is a parsed spawnset file.
+type Spawnset struct {
+	SpawnVersion int32
+	WorldVersion int32
+	// ShrinkStart and ShrinkEnd are the arena radius at the start and the end
+	// of the shrinking, and ShrinkRate how fast it shrinks per second.
+	ShrinkStart float32
+	ShrinkEnd   float32
+	ShrinkRate  float32
+	Brightness  float32
+	GameMode    GameMode
+	// Arena holds the height of each tile. Tiles below VoidHeight are void.
+	Arena  [ArenaSize][ArenaSize]float32
+	Spawns []Spawn
+	// HandLevel is the hand level the run starts at, from 1 to 4.
+	HandLevel int32
+	// AdditionalGems is the number of gems the run starts with on top of those
+	// the hand level needs.
+	AdditionalGems int32
+	// TimerStart is the time the run's timer starts at.
+	TimerStart float32
+
+	md5 [16]byte
+}
+
+// VoidHeight is the height below which an arena tile is void.
+const VoidHeight = -1
+
+// ReadFile parses the spawnset file at path.
+func ReadFile(path string) (*Spawnset, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: could not read spawnset: %w", err)
+	}
+	s, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFile: %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses the contents of a spawnset file.
+func Parse(b []byte) (*Spawnset, error) {
+	if len(b) < headerSize+arenaBytes+spawnsHeaderSize {
+		return nil, errors.New("Parse: file is too short to be a spawnset")
+	}
+
+	s := &Spawnset{
+		SpawnVersion: readInt32(b, 0),
+		WorldVersion: readInt32(b, 4),
+		ShrinkEnd:    readFloat32(b, 8),
+		ShrinkStart:  readFloat32(b, 12),
+		ShrinkRate:   readFloat32(b, 16),
+		Brightness:   readFloat32(b, 20),
+		GameMode:     GameMode(readInt32(b, 24)),
+		HandLevel:    1,
+		md5:          md5.Sum(b),
+	}
+
+	offset := headerSize
+	for x := 0; x < ArenaSize; x++ {
+		for y := 0; y < ArenaSize; y++ {
+			s.Arena[x][y] = readFloat32(b, offset)
+			offset += 4
+		}
+	}
+
+	spawnCount := int(readInt32(b, offset+spawnsHeaderSize-4))
+	offset += spawnsHeaderSize
+	if spawnCount < 0 || spawnCount > maxSpawns || len(b) < offset+spawnCount*spawnSize {
+		return nil, fmt.Errorf("Parse: invalid spawn count %d", spawnCount)
+	}
+
+	s.Spawns = make([]Spawn, spawnCount)
+	for i := range s.Spawns {
+		code := readInt32(b, offset)
+		spawn := Spawn{Delay: readFloat32(b, offset+4)}
+		switch {
+		case code == emptyCode:
+			spawn.Empty = true
+		case code >= 0 && int(code) < len(enemyCodes):
+			spawn.Enemy = enemyCodes[code]
+		default:
+			return nil, fmt.Errorf("Parse: unknown enemy code %d in spawn %d", code, i)
+		}
+		s.Spawns[i] = spawn
+		offset += spawnSize
+	}
+
+	// Spawn versions after 4 end with the starting settings.
+	if s.SpawnVersion > 4 {
+		if len(b) < offset+5 {
+			return nil, errors.New("Parse: file is too short for its settings")
+		}
+		s.HandLevel = int32(b[offset])
+		s.AdditionalGems = readInt32(b, offset+1)
+		offset += 5
+		if s.SpawnVersion > 5 {
+			if len(b) < offset+4 {
+				return nil, errors.New("Parse: file is too short for its timer start")
+			}
+			s.TimerStart = readFloat32(b, offset)
+		}
+	}
+
+	return s, nil
+}
+
+// MD5 returns the hash of the spawnset file, which is what the game reports as
+// the level hash of a run.
+func (s *Spawnset) MD5() [16]byte {
+	return s.md5
+}
+
+// MD5String returns the hash in the format of devildaggers.Snapshot.GetLevelHashMD5.
+func (s *Spawnset) MD5String() string {
+	return fmt.Sprintf("%x", s.md5)
+}
+
+// HandStart returns the hand level and additional gems runs on the spawnset
+// start with.
+func (s *Spawnset) HandStart() devildaggers.HandStart {
+	return devildaggers.HandStart{
+		Level:          devildaggers.HandLevel(s.HandLevel),
+		AdditionalGems: s.AdditionalGems,
+	}
+}
+
+// IsVoid reports whether the arena tile at x, y is void.
+func (s *Spawnset) IsVoid(x, y int) bool {
+	return s.Arena[x][y] < VoidHeight
+}
+
+// loopStart returns the index of the first spawn of the loop, which follows the
+// last empty spawn.
+func (s *Spawnset) loopStart() int {
+	for i := len(s.Spawns) - 1; i >= 0; i-- {
+		if s.Spawns[i].Empty {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func readInt32(b []byte, offset int) int32 {
+	return int32(binary.LittleEndian.Uint32(b[offset:]))
+}
+
+func readFloat32(b []byte, offset int) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(b[offset:]))
+}
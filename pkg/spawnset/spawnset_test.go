@@ -0,0 +1,251 @@
+package spawnset
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+)
+
+// testSpawn is a spawn list entry as written in a spawnset file.
+type testSpawn struct {
+	code  int32
+	delay float32
+}
+
+// buildSpawnset lays out a spawnset file of the given spawn version with an
+// arena of flat tiles, followed by settings, the bytes after the spawn list.
+func buildSpawnset(version int32, mode GameMode, spawns []testSpawn, settings []byte) []byte {
+	b := make([]byte, headerSize+arenaBytes+spawnsHeaderSize+len(spawns)*spawnSize)
+	binary.LittleEndian.PutUint32(b[0:], uint32(version))
+	binary.LittleEndian.PutUint32(b[4:], 9)
+	binary.LittleEndian.PutUint32(b[8:], math.Float32bits(20))
+	binary.LittleEndian.PutUint32(b[12:], math.Float32bits(50))
+	binary.LittleEndian.PutUint32(b[16:], math.Float32bits(0.025))
+	binary.LittleEndian.PutUint32(b[20:], math.Float32bits(60))
+	binary.LittleEndian.PutUint32(b[24:], uint32(mode))
+
+	offset := headerSize + arenaBytes + spawnsHeaderSize
+	binary.LittleEndian.PutUint32(b[offset-4:], uint32(len(spawns)))
+	for _, s := range spawns {
+		binary.LittleEndian.PutUint32(b[offset:], uint32(s.code))
+		binary.LittleEndian.PutUint32(b[offset+4:], math.Float32bits(s.delay))
+		offset += spawnSize
+	}
+	return append(b, settings...)
+}
+
+// setTile sets the height of the arena tile at x, y in a built spawnset file.
+func setTile(b []byte, x, y int, height float32) {
+	binary.LittleEndian.PutUint32(b[headerSize+(x*ArenaSize+y)*4:], math.Float32bits(height))
+}
+
+// testSettings returns the settings of a spawn version 6 file.
+func testSettings(handLevel byte, additionalGems int32, timerStart float32) []byte {
+	b := make([]byte, 9)
+	b[0] = handLevel
+	binary.LittleEndian.PutUint32(b[1:], uint32(additionalGems))
+	binary.LittleEndian.PutUint32(b[5:], math.Float32bits(timerStart))
+	return b
+}
+
+func TestParse(t *testing.T) {
+	b := buildSpawnset(6, GameModeTimeAttack, []testSpawn{{0, 3}, {-1, 2}, {7, 5}}, testSettings(3, 25, 10))
+	setTile(b, 1, 2, -2)
+	setTile(b, 50, 50, 3)
+
+	s, err := Parse(b)
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if s.SpawnVersion != 6 || s.WorldVersion != 9 || s.ShrinkEnd != 20 || s.ShrinkStart != 50 ||
+		s.ShrinkRate != 0.025 || s.Brightness != 60 || s.GameMode != GameModeTimeAttack {
+		t.Errorf("header = %+v", s)
+	}
+	if s.Arena[1][2] != -2 || s.Arena[50][50] != 3 || s.Arena[2][1] != 0 {
+		t.Errorf("arena tiles = %v, %v, %v, want -2, 3, 0", s.Arena[1][2], s.Arena[50][50], s.Arena[2][1])
+	}
+	if !s.IsVoid(1, 2) || s.IsVoid(50, 50) {
+		t.Errorf("IsVoid() = %v, %v, want true, false", s.IsVoid(1, 2), s.IsVoid(50, 50))
+	}
+	wantSpawns := []Spawn{{Enemy: devildaggers.EnemySquid1, Delay: 3}, {Empty: true, Delay: 2}, {Enemy: devildaggers.EnemyGigapede, Delay: 5}}
+	if len(s.Spawns) != len(wantSpawns) {
+		t.Fatalf("Spawns = %+v, want %+v", s.Spawns, wantSpawns)
+	}
+	for i := range wantSpawns {
+		if s.Spawns[i] != wantSpawns[i] {
+			t.Errorf("Spawns[%d] = %+v, want %+v", i, s.Spawns[i], wantSpawns[i])
+		}
+	}
+	if start := s.HandStart(); start != (devildaggers.HandStart{Level: devildaggers.HandLevel3, AdditionalGems: 25}) || s.TimerStart != 10 {
+		t.Errorf("HandStart() = %+v and TimerStart = %v", start, s.TimerStart)
+	}
+
+	// The game reports the MD5 of the whole file as the level hash, in lowercase hex.
+	sum := md5.Sum(b)
+	if s.MD5() != sum || s.MD5String() != hex.EncodeToString(sum[:]) {
+		t.Errorf("MD5String() = %s, want %x", s.MD5String(), sum)
+	}
+}
+
+func TestParseSettingsByVersion(t *testing.T) {
+	spawns := []testSpawn{{0, 3}}
+	tests := []struct {
+		name           string
+		version        int32
+		settings       []byte
+		wantLevel      int32
+		wantGems       int32
+		wantTimerStart float32
+	}{
+		{"version 4 has no settings", 4, nil, 1, 0, 0},
+		{"version 4 ignores trailing bytes", 4, testSettings(3, 25, 10), 1, 0, 0},
+		{"version 5 has the hand level and gems", 5, testSettings(2, 7, 10)[:5], 2, 7, 0},
+		{"version 6 adds the timer start", 6, testSettings(4, 40, 12.5), 4, 40, 12.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(buildSpawnset(tt.version, GameModeSurvival, spawns, tt.settings))
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if s.HandLevel != tt.wantLevel || s.AdditionalGems != tt.wantGems || s.TimerStart != tt.wantTimerStart {
+				t.Errorf("settings = %d, %d, %v, want %d, %d, %v", s.HandLevel, s.AdditionalGems, s.TimerStart,
+					tt.wantLevel, tt.wantGems, tt.wantTimerStart)
+			}
+		})
+	}
+}
+
+func TestParseEnemyCodes(t *testing.T) {
+	want := []devildaggers.EnemyType{
+		devildaggers.EnemySquid1, devildaggers.EnemySquid2, devildaggers.EnemySquid3, devildaggers.EnemyLeviathan,
+		devildaggers.EnemySpider1, devildaggers.EnemySpider2, devildaggers.EnemyCentipede, devildaggers.EnemyGigapede,
+		devildaggers.EnemyGhostpede, devildaggers.EnemyThorn,
+	}
+	for code, enemy := range want {
+		s, err := Parse(buildSpawnset(4, GameModeSurvival, []testSpawn{{int32(code), 1}}, nil))
+		if err != nil {
+			t.Fatalf("Parse() of code %d = %v", code, err)
+		}
+		if got := s.Spawns[0]; got.Empty || got.Enemy != enemy {
+			t.Errorf("code %d = %+v, want %s", code, got, enemy)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := buildSpawnset(6, GameModeSurvival, []testSpawn{{0, 3}, {-1, 2}}, testSettings(1, 0, 0))
+	withCount := func(n int32) []byte {
+		b := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(b[headerSize+arenaBytes+spawnsHeaderSize-4:], uint32(n))
+		return b
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"empty", nil, "too short to be a spawnset"},
+		{"no spawn list header", valid[:headerSize+arenaBytes], "too short to be a spawnset"},
+		{"negative spawn count", withCount(-1), "invalid spawn count -1"},
+		{"huge spawn count", withCount(maxSpawns + 1), "invalid spawn count"},
+		{"spawn count past the end", withCount(1000), "invalid spawn count 1000"},
+		{"unknown enemy code", buildSpawnset(4, GameModeSurvival, []testSpawn{{0, 1}, {10, 1}}, nil), "unknown enemy code 10 in spawn 1"},
+		{"negative enemy code", buildSpawnset(4, GameModeSurvival, []testSpawn{{-2, 1}}, nil), "unknown enemy code -2"},
+		{"version 5 without settings", buildSpawnset(5, GameModeSurvival, nil, []byte{1, 0}), "too short for its settings"},
+		{"version 6 without a timer start", buildSpawnset(6, GameModeSurvival, nil, testSettings(1, 0, 0)[:7]), "too short for its timer start"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.b)
+			if err == nil {
+				t.Fatalf("Parse() = %+v, want an error", s)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	squid, spider, giga, ghost := devildaggers.EnemySquid1, devildaggers.EnemySpider1, devildaggers.EnemyGigapede, devildaggers.EnemyGhostpede
+	tests := []struct {
+		name       string
+		mode       GameMode
+		timerStart float32
+		spawns     []Spawn
+		until      float64
+		want       []ScheduledSpawn
+	}{
+		{
+			name:   "no loop outside survival",
+			mode:   GameModeRace,
+			spawns: []Spawn{{Enemy: squid, Delay: 1}, {Empty: true, Delay: 2}, {Enemy: spider, Delay: 4}},
+			until:  100,
+			want:   []ScheduledSpawn{{1, squid, 0}, {7, spider, 0}},
+		},
+		{
+			name:   "survival loop speeds up and spawns ghostpedes",
+			mode:   GameModeSurvival,
+			spawns: []Spawn{{Enemy: squid, Delay: 1}, {Empty: true, Delay: 2}, {Enemy: spider, Delay: 4}, {Enemy: giga, Delay: 4}},
+			until:  30,
+			want: []ScheduledSpawn{
+				{1, squid, 0},
+				{7, spider, 1}, {11, giga, 1},
+				{11 + 4/1.125, spider, 2}, {11 + 8/1.125, giga, 2},
+				{11 + 8/1.125 + 4/1.25, spider, 3}, {11 + 8/1.125 + 8/1.25, ghost, 3},
+				{11 + 8/1.125 + 8/1.25 + 4/1.375, spider, 4},
+			},
+		},
+		{
+			name:   "everything loops without an empty spawn",
+			mode:   GameModeSurvival,
+			spawns: []Spawn{{Enemy: squid, Delay: 5}},
+			until:  11,
+			want:   []ScheduledSpawn{{5, squid, 1}, {5 + 5/1.125, squid, 2}},
+		},
+		{
+			name:       "timer start shifts the spawns",
+			mode:       GameModeRace,
+			timerStart: 10,
+			spawns:     []Spawn{{Enemy: squid, Delay: 1}, {Enemy: spider, Delay: 4}},
+			until:      3,
+			want:       []ScheduledSpawn{{11, squid, 0}},
+		},
+		{
+			name:   "loop of zero delays does not repeat forever",
+			mode:   GameModeSurvival,
+			spawns: []Spawn{{Enemy: squid, Delay: 1}, {Empty: true, Delay: 0}, {Enemy: spider, Delay: 0}, {Enemy: giga, Delay: 0}},
+			until:  1000,
+			want:   []ScheduledSpawn{{1, squid, 0}},
+		},
+		{
+			name:   "nothing before the first spawn",
+			mode:   GameModeSurvival,
+			spawns: []Spawn{{Enemy: squid, Delay: 3}, {Empty: true, Delay: 1}},
+			until:  2,
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Spawnset{GameMode: tt.mode, TimerStart: tt.timerStart, Spawns: tt.spawns}
+			got := s.Timeline(tt.until)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Timeline() = %+v, want %+v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i].Enemy != tt.want[i].Enemy || got[i].Wave != tt.want[i].Wave || math.Abs(got[i].Second-tt.want[i].Second) > 1e-4 {
+					t.Errorf("Timeline()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package spawnset
+
+import "github.com/alexwilkerson/ddstats-go/pkg/devildaggers"
+
+const (
+	// loopSpeedup is how much faster each wave of the survival loop runs than
+	// the first one: the delays of wave n are divided by 1 + n*loopSpeedup.
+	loopSpeedup = 0.125
+	// ghostpedeWave is how often, in waves of the loop, gigapedes are replaced
+	// by ghostpedes.
+	ghostpedeWave = 3
+)
+
+// ScheduledSpawn is an enemy spawning at a given time of a run.
+type ScheduledSpawn struct {
+	// Second is the run's timer when the enemy spawns, which starts at TimerStart.
+	Second float64
+	Enemy  devildaggers.EnemyType
+	// Wave is the wave of the survival loop the spawn belongs to, starting at 1,
+	// or 0 for spawns before the loop.
+	Wave int
+}
+
+// Timeline returns every enemy spawning in the first until seconds of a run, in
+// order. In survival mode the spawns after the last empty spawn loop forever,
+// faster every wave, with every third wave spawning ghostpedes in place of
+// gigapedes. The other game modes do not loop.
+func (s *Spawnset) Timeline(until float64) []ScheduledSpawn {
+	var spawns []ScheduledSpawn
+	second := float64(s.TimerStart)
+	until += second
+
+	loopStart := s.loopStart()
+	for _, spawn := range s.Spawns[:loopStart] {
+		second += float64(spawn.Delay)
+		if second > until {
+			return spawns
+		}
+		if !spawn.Empty {
+			spawns = append(spawns, ScheduledSpawn{Second: second, Enemy: spawn.Enemy})
+		}
+	}
+
+	loop := s.Spawns[loopStart:]
+	if s.GameMode != GameModeSurvival {
+		for _, spawn := range loop {
+			second += float64(spawn.Delay)
+			if second > until {
+				return spawns
+			}
+			spawns = append(spawns, ScheduledSpawn{Second: second, Enemy: spawn.Enemy})
+		}
+		return spawns
+	}
+	if !loopAdvances(loop) {
+		return spawns
+	}
+
+	for wave := 0; ; wave++ {
+		speed := 1 + float64(wave)*loopSpeedup
+		for _, spawn := range loop {
+			second += float64(spawn.Delay) / speed
+			if second > until {
+				return spawns
+			}
+			enemy := spawn.Enemy
+			if enemy == devildaggers.EnemyGigapede && wave%ghostpedeWave == ghostpedeWave-1 {
+				enemy = devildaggers.EnemyGhostpede
+			}
+			spawns = append(spawns, ScheduledSpawn{Second: second, Enemy: enemy, Wave: wave + 1})
+		}
+	}
+}
+
+// loopAdvances reports whether looping the spawns moves time forward, so a loop
+// of zero delays does not repeat forever.
+func loopAdvances(loop []Spawn) bool {
+	for _, spawn := range loop {
+		if spawn.Delay > 0 {
+			return true
+		}
+	}
+	return false
+}